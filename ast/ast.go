@@ -0,0 +1,408 @@
+// Package jack_ast declares the types used to represent the syntax tree
+// of a Jack class.
+package jack_ast
+
+import (
+	"sort"
+
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+)
+
+type Position = jack_tokenizer.Position
+
+// Node is implemented by every node of the tree.
+type Node interface {
+	Pos() Position // first byte of the node
+	End() Position // one past the last byte of the node
+}
+
+// Expr is implemented by every expression node.
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// Stmt is implemented by every statement node.
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// after returns the position n bytes past p, which must not cross a line
+// break.
+func after(p Position, n int) Position {
+	if !p.IsValid() {
+		return p
+	}
+	p.Column += n
+	p.Offset += n
+	return p
+}
+
+// Declarations
+
+// Class is the root of the tree: a whole .jack file.
+type Class struct {
+	Class       Position // position of "class"
+	Name        *Ident
+	Lbrace      Position
+	Vars        []*ClassVarDec
+	Consts      []*ConstDec // with the constants dialect only
+	Enums       []*EnumDec  // with the constants dialect only
+	Subroutines []*SubroutineDec
+	Rbrace      Position
+}
+
+// Decls returns the variable, constant and enum declarations of the
+// class in source order.
+func (c *Class) Decls() []Node {
+	decls := make([]Node, 0, len(c.Vars)+len(c.Consts)+len(c.Enums))
+	for _, dec := range c.Vars {
+		decls = append(decls, dec)
+	}
+	for _, dec := range c.Consts {
+		decls = append(decls, dec)
+	}
+	for _, dec := range c.Enums {
+		decls = append(decls, dec)
+	}
+	sort.SliceStable(decls, func(i, j int) bool {
+		return decls[i].Pos().Offset < decls[j].Pos().Offset
+	})
+
+	return decls
+}
+
+// ClassVarDec declares static variables or fields.
+type ClassVarDec struct {
+	KeywordPos Position
+	Keyword    jack_tokenizer.TokenSubtype // KW_STATIC or KW_FIELD
+	Type       *TypeName
+	Names      []*Ident
+	Semicolon  Position
+}
+
+// ConstDec declares a constant of the class, whose value is worked out
+// at compile time.
+type ConstDec struct {
+	Const     Position // position of "const"
+	Type      *TypeName
+	Name      *Ident
+	Value     Expr
+	Semicolon Position
+}
+
+// EnumDec declares an enum, whose members are the constants 0, 1, 2 and
+// so on, in order.
+type EnumDec struct {
+	Enum    Position // position of "enum"
+	Name    *Ident
+	Lbrace  Position
+	Members []*Ident
+	Rbrace  Position
+}
+
+// TypeName names the type of a variable or the return type of a
+// subroutine.
+type TypeName struct {
+	NamePos Position
+	Name    string
+	Keyword jack_tokenizer.TokenSubtype // KW_INT, KW_CHAR, KW_BOOLEAN, KW_VOID, or NONE for a class
+}
+
+// SubroutineDec declares a constructor, function or method.
+type SubroutineDec struct {
+	KeywordPos Position
+	Keyword    jack_tokenizer.TokenSubtype // KW_CONSTRUCTOR, KW_FUNCTION or KW_METHOD
+	Return     *TypeName
+	Name       *Ident
+	Lparen     Position
+	Params     []*Param
+	Rparen     Position
+	Body       *SubroutineBody
+}
+
+// Param is a single entry of a parameter list.
+type Param struct {
+	Type *TypeName
+	Name *Ident
+}
+
+// SubroutineBody holds the local variables and statements of a
+// subroutine.
+type SubroutineBody struct {
+	Lbrace Position
+	Vars   []*VarDec
+	Stmts  []Stmt
+	Rbrace Position
+}
+
+// VarDec declares local variables.
+type VarDec struct {
+	Var       Position // position of "var"
+	Type      *TypeName
+	Names     []*Ident
+	Semicolon Position
+}
+
+func (c *Class) Pos() Position          { return c.Class }
+func (c *Class) End() Position          { return after(c.Rbrace, 1) }
+func (d *ClassVarDec) Pos() Position    { return d.KeywordPos }
+func (d *ClassVarDec) End() Position    { return after(d.Semicolon, 1) }
+func (t *TypeName) Pos() Position       { return t.NamePos }
+func (t *TypeName) End() Position       { return after(t.NamePos, len(t.Name)) }
+func (d *SubroutineDec) Pos() Position  { return d.KeywordPos }
+func (d *SubroutineDec) End() Position  { return d.Body.End() }
+func (p *Param) Pos() Position          { return p.Type.Pos() }
+func (p *Param) End() Position          { return p.Name.End() }
+func (b *SubroutineBody) Pos() Position { return b.Lbrace }
+func (b *SubroutineBody) End() Position { return after(b.Rbrace, 1) }
+func (d *VarDec) Pos() Position         { return d.Var }
+func (d *VarDec) End() Position         { return after(d.Semicolon, 1) }
+func (d *ConstDec) Pos() Position       { return d.Const }
+func (d *ConstDec) End() Position       { return after(d.Semicolon, 1) }
+func (d *EnumDec) Pos() Position        { return d.Enum }
+func (d *EnumDec) End() Position        { return after(d.Rbrace, 1) }
+
+// Statements
+
+// Block is a braced list of statements, the body of an if or while.
+type Block struct {
+	Lbrace Position
+	Stmts  []Stmt
+	Rbrace Position
+}
+
+type LetStmt struct {
+	Let       Position // position of "let"
+	Name      *Ident
+	Index     Expr                        // nil unless an array element is assigned
+	AssignPos Position                    // position of Assign
+	Assign    jack_tokenizer.TokenSubtype // SYM_EQUALS, or a compound assignment such as SYM_PLUS_EQUALS
+	Value     Expr
+	Semicolon Position // invalid in the header of a for loop
+}
+
+type IfStmt struct {
+	If      Position // position of "if"
+	Cond    Expr
+	Body    *Block
+	ElsePos Position // position of "else", if any
+	Else    Stmt     // *Block, *IfStmt for else if, or nil
+}
+
+type WhileStmt struct {
+	While Position // position of "while"
+	Cond  Expr
+	Body  *Block
+}
+
+type DoStmt struct {
+	Do        Position // position of "do"
+	Call      *CallExpr
+	Semicolon Position // invalid in the header of a for loop
+}
+
+type ReturnStmt struct {
+	Return    Position // position of "return"
+	Value     Expr     // nil for a bare return
+	Semicolon Position
+}
+
+// ForStmt is a C-style for loop. Init runs once before the loop and
+// Post after every pass through Body, including one cut short by
+// continue. Init and Post are *LetStmt or *DoStmt; the LetStmt or DoStmt
+// in Post has no semicolon.
+type ForStmt struct {
+	For    Position // position of "for"
+	Lparen Position
+	Init   Stmt // or nil
+	Cond   Expr // or nil, to loop until break
+	Post   Stmt // or nil
+	Rparen Position
+	Body   *Block
+}
+
+type BreakStmt struct {
+	Break     Position // position of "break"
+	Semicolon Position
+}
+
+type ContinueStmt struct {
+	Continue  Position // position of "continue"
+	Semicolon Position
+}
+
+// BadStmt stands in for a statement that could not be parsed.
+type BadStmt struct {
+	From, To Position
+}
+
+func (s *Block) Pos() Position        { return s.Lbrace }
+func (s *Block) End() Position        { return after(s.Rbrace, 1) }
+func (s *LetStmt) Pos() Position      { return s.Let }
+func (s *IfStmt) Pos() Position       { return s.If }
+func (s *WhileStmt) Pos() Position    { return s.While }
+func (s *WhileStmt) End() Position    { return s.Body.End() }
+func (s *DoStmt) Pos() Position       { return s.Do }
+func (s *ReturnStmt) Pos() Position   { return s.Return }
+func (s *ReturnStmt) End() Position   { return after(s.Semicolon, 1) }
+func (s *ForStmt) Pos() Position      { return s.For }
+func (s *ForStmt) End() Position      { return s.Body.End() }
+func (s *BreakStmt) Pos() Position    { return s.Break }
+func (s *BreakStmt) End() Position    { return after(s.Semicolon, 1) }
+func (s *ContinueStmt) Pos() Position { return s.Continue }
+func (s *ContinueStmt) End() Position { return after(s.Semicolon, 1) }
+func (s *BadStmt) Pos() Position      { return s.From }
+func (s *BadStmt) End() Position      { return s.To }
+
+func (s *LetStmt) End() Position {
+	if !s.Semicolon.IsValid() {
+		return s.Value.End()
+	}
+	return after(s.Semicolon, 1)
+}
+
+func (s *DoStmt) End() Position {
+	if !s.Semicolon.IsValid() {
+		return s.Call.End()
+	}
+	return after(s.Semicolon, 1)
+}
+
+func (s *IfStmt) End() Position {
+	if s.Else != nil {
+		return s.Else.End()
+	}
+	return s.Body.End()
+}
+
+func (*Block) stmtNode()        {}
+func (*LetStmt) stmtNode()      {}
+func (*IfStmt) stmtNode()       {}
+func (*WhileStmt) stmtNode()    {}
+func (*DoStmt) stmtNode()       {}
+func (*ReturnStmt) stmtNode()   {}
+func (*ForStmt) stmtNode()      {}
+func (*BreakStmt) stmtNode()    {}
+func (*ContinueStmt) stmtNode() {}
+func (*BadStmt) stmtNode()      {}
+
+// Expressions
+
+// Ident is a variable, class or subroutine name.
+type Ident struct {
+	NamePos Position
+	Name    string
+}
+
+// IntLit is an integer constant. Value is in decimal even when the
+// source spelled it some other way.
+type IntLit struct {
+	ValuePos Position
+	Value    string
+	Raw      string
+}
+
+// StringLit is a string constant. Value holds the decoded string, Raw
+// the constant as written, quotes and all.
+type StringLit struct {
+	ValuePos Position
+	Value    string
+	Raw      string
+}
+
+// KeywordLit is one of true, false, null and this.
+type KeywordLit struct {
+	ValuePos Position
+	Keyword  jack_tokenizer.TokenSubtype
+}
+
+type ParenExpr struct {
+	Lparen Position
+	X      Expr
+	Rparen Position
+}
+
+type UnaryExpr struct {
+	OpPos Position
+	Op    jack_tokenizer.TokenSubtype // SYM_MINUS or SYM_TILDE
+	X     Expr
+}
+
+type BinaryExpr struct {
+	X     Expr
+	OpPos Position
+	Op    jack_tokenizer.TokenSubtype
+	Y     Expr
+}
+
+// IndexExpr is an array element, a[i].
+type IndexExpr struct {
+	X      *Ident
+	Lbrack Position
+	Index  Expr
+	Rbrack Position
+}
+
+// CallExpr is a subroutine call, f(x), obj.f(x) or Class.f(x).
+type CallExpr struct {
+	Receiver *Ident // nil for an unqualified call
+	Name     *Ident
+	Lparen   Position
+	Args     []Expr
+	Rparen   Position
+}
+
+// SelectorExpr is a constant of a class or a member of an enum,
+// Game.MAX or Dir.UP.
+type SelectorExpr struct {
+	X   *Ident
+	Sel *Ident
+}
+
+// BadExpr stands in for an expression that could not be parsed.
+type BadExpr struct {
+	From, To Position
+}
+
+func (x *Ident) Pos() Position        { return x.NamePos }
+func (x *Ident) End() Position        { return after(x.NamePos, len(x.Name)) }
+func (x *IntLit) Pos() Position       { return x.ValuePos }
+func (x *IntLit) End() Position       { return after(x.ValuePos, len(x.Raw)) }
+func (x *StringLit) Pos() Position    { return x.ValuePos }
+func (x *StringLit) End() Position    { return after(x.ValuePos, len(x.Raw)) }
+func (x *KeywordLit) Pos() Position   { return x.ValuePos }
+func (x *KeywordLit) End() Position   { return after(x.ValuePos, len(x.Keyword.Spelling())) }
+func (x *ParenExpr) Pos() Position    { return x.Lparen }
+func (x *ParenExpr) End() Position    { return after(x.Rparen, 1) }
+func (x *UnaryExpr) Pos() Position    { return x.OpPos }
+func (x *UnaryExpr) End() Position    { return x.X.End() }
+func (x *BinaryExpr) Pos() Position   { return x.X.Pos() }
+func (x *BinaryExpr) End() Position   { return x.Y.End() }
+func (x *IndexExpr) Pos() Position    { return x.X.Pos() }
+func (x *IndexExpr) End() Position    { return after(x.Rbrack, 1) }
+func (x *SelectorExpr) Pos() Position { return x.X.Pos() }
+func (x *SelectorExpr) End() Position { return x.Sel.End() }
+func (x *BadExpr) Pos() Position      { return x.From }
+func (x *BadExpr) End() Position      { return x.To }
+
+func (x *CallExpr) Pos() Position {
+	if x.Receiver != nil {
+		return x.Receiver.Pos()
+	}
+	return x.Name.Pos()
+}
+func (x *CallExpr) End() Position { return after(x.Rparen, 1) }
+
+func (*Ident) exprNode()        {}
+func (*IntLit) exprNode()       {}
+func (*StringLit) exprNode()    {}
+func (*KeywordLit) exprNode()   {}
+func (*ParenExpr) exprNode()    {}
+func (*UnaryExpr) exprNode()    {}
+func (*BinaryExpr) exprNode()   {}
+func (*IndexExpr) exprNode()    {}
+func (*CallExpr) exprNode()     {}
+func (*SelectorExpr) exprNode() {}
+func (*BadExpr) exprNode()      {}
@@ -0,0 +1,306 @@
+package jack_ast
+
+import "fmt"
+
+// A Visitor's Visit method is called for each node met by Walk. If the
+// visitor w it returns is not nil, Walk visits the children of node with
+// w, and then calls w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the tree rooted at node depth-first, in source order.
+// It starts by calling v.Visit(node); node must not be nil.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	// Declarations
+	case *Class:
+		Walk(v, n.Name)
+		for _, dec := range n.Decls() {
+			Walk(v, dec)
+		}
+		for _, dec := range n.Subroutines {
+			Walk(v, dec)
+		}
+	case *ClassVarDec:
+		Walk(v, n.Type)
+		walkIdentList(v, n.Names)
+	case *ConstDec:
+		Walk(v, n.Type)
+		Walk(v, n.Name)
+		Walk(v, n.Value)
+	case *EnumDec:
+		Walk(v, n.Name)
+		walkIdentList(v, n.Members)
+	case *TypeName:
+	case *SubroutineDec:
+		Walk(v, n.Return)
+		Walk(v, n.Name)
+		for _, param := range n.Params {
+			Walk(v, param)
+		}
+		Walk(v, n.Body)
+	case *Param:
+		Walk(v, n.Type)
+		Walk(v, n.Name)
+	case *SubroutineBody:
+		for _, dec := range n.Vars {
+			Walk(v, dec)
+		}
+		walkStmtList(v, n.Stmts)
+	case *VarDec:
+		Walk(v, n.Type)
+		walkIdentList(v, n.Names)
+
+	// Statements
+	case *Block:
+		walkStmtList(v, n.Stmts)
+	case *LetStmt:
+		Walk(v, n.Name)
+		if n.Index != nil {
+			Walk(v, n.Index)
+		}
+		Walk(v, n.Value)
+	case *IfStmt:
+		Walk(v, n.Cond)
+		Walk(v, n.Body)
+		if n.Else != nil {
+			Walk(v, n.Else)
+		}
+	case *WhileStmt:
+		Walk(v, n.Cond)
+		Walk(v, n.Body)
+	case *DoStmt:
+		Walk(v, n.Call)
+	case *ReturnStmt:
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+	case *ForStmt:
+		if n.Init != nil {
+			Walk(v, n.Init)
+		}
+		if n.Cond != nil {
+			Walk(v, n.Cond)
+		}
+		if n.Post != nil {
+			Walk(v, n.Post)
+		}
+		Walk(v, n.Body)
+	case *BreakStmt, *ContinueStmt, *BadStmt:
+
+	// Expressions
+	case *Ident, *IntLit, *StringLit, *KeywordLit, *BadExpr:
+	case *ParenExpr:
+		Walk(v, n.X)
+	case *UnaryExpr:
+		Walk(v, n.X)
+	case *BinaryExpr:
+		Walk(v, n.X)
+		Walk(v, n.Y)
+	case *IndexExpr:
+		Walk(v, n.X)
+		Walk(v, n.Index)
+	case *CallExpr:
+		if n.Receiver != nil {
+			Walk(v, n.Receiver)
+		}
+		Walk(v, n.Name)
+		for _, arg := range n.Args {
+			Walk(v, arg)
+		}
+	case *SelectorExpr:
+		Walk(v, n.X)
+		Walk(v, n.Sel)
+
+	default:
+		panic(fmt.Sprintf("jack_ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkIdentList(v Visitor, list []*Ident) {
+	for _, x := range list {
+		Walk(v, x)
+	}
+}
+
+func walkStmtList(v Visitor, list []Stmt) {
+	for _, x := range list {
+		Walk(v, x)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the tree rooted at node depth-first, calling f(node)
+// for each node. If f returns true, Inspect goes on to the children of
+// node, followed by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Rewrite traverses the tree rooted at node bottom-up, replacing every
+// node n with f(n) once its children have been rewritten, and returns
+// the replacement for node itself. f returns n to keep a node. Nodes f
+// leaves alone keep their positions; a replacement must fit where the
+// node was, an Expr for an Expr and a *Block for a *Block, or Rewrite
+// panics.
+func Rewrite(node Node, f func(Node) Node) Node {
+	r := rewriter(f)
+	return r.node(node)
+}
+
+type rewriter func(Node) Node
+
+func (r rewriter) node(node Node) Node {
+	switch n := node.(type) {
+	// Declarations
+	case *Class:
+		n.Name = r.ident(n.Name)
+		for i, dec := range n.Vars {
+			n.Vars[i] = rewriteAs[*ClassVarDec](r, dec)
+		}
+		for i, dec := range n.Consts {
+			n.Consts[i] = rewriteAs[*ConstDec](r, dec)
+		}
+		for i, dec := range n.Enums {
+			n.Enums[i] = rewriteAs[*EnumDec](r, dec)
+		}
+		for i, dec := range n.Subroutines {
+			n.Subroutines[i] = rewriteAs[*SubroutineDec](r, dec)
+		}
+	case *ClassVarDec:
+		n.Type = rewriteAs[*TypeName](r, n.Type)
+		r.idents(n.Names)
+	case *ConstDec:
+		n.Type = rewriteAs[*TypeName](r, n.Type)
+		n.Name = r.ident(n.Name)
+		n.Value = r.expr(n.Value)
+	case *EnumDec:
+		n.Name = r.ident(n.Name)
+		r.idents(n.Members)
+	case *TypeName:
+	case *SubroutineDec:
+		n.Return = rewriteAs[*TypeName](r, n.Return)
+		n.Name = r.ident(n.Name)
+		for i, param := range n.Params {
+			n.Params[i] = rewriteAs[*Param](r, param)
+		}
+		n.Body = rewriteAs[*SubroutineBody](r, n.Body)
+	case *Param:
+		n.Type = rewriteAs[*TypeName](r, n.Type)
+		n.Name = r.ident(n.Name)
+	case *SubroutineBody:
+		for i, dec := range n.Vars {
+			n.Vars[i] = rewriteAs[*VarDec](r, dec)
+		}
+		r.stmts(n.Stmts)
+	case *VarDec:
+		n.Type = rewriteAs[*TypeName](r, n.Type)
+		r.idents(n.Names)
+
+	// Statements
+	case *Block:
+		r.stmts(n.Stmts)
+	case *LetStmt:
+		n.Name = r.ident(n.Name)
+		if n.Index != nil {
+			n.Index = r.expr(n.Index)
+		}
+		n.Value = r.expr(n.Value)
+	case *IfStmt:
+		n.Cond = r.expr(n.Cond)
+		n.Body = rewriteAs[*Block](r, n.Body)
+		if n.Else != nil {
+			n.Else = rewriteAs[Stmt](r, n.Else)
+		}
+	case *WhileStmt:
+		n.Cond = r.expr(n.Cond)
+		n.Body = rewriteAs[*Block](r, n.Body)
+	case *DoStmt:
+		n.Call = rewriteAs[*CallExpr](r, n.Call)
+	case *ReturnStmt:
+		if n.Value != nil {
+			n.Value = r.expr(n.Value)
+		}
+	case *ForStmt:
+		if n.Init != nil {
+			n.Init = rewriteAs[Stmt](r, n.Init)
+		}
+		if n.Cond != nil {
+			n.Cond = r.expr(n.Cond)
+		}
+		if n.Post != nil {
+			n.Post = rewriteAs[Stmt](r, n.Post)
+		}
+		n.Body = rewriteAs[*Block](r, n.Body)
+	case *BreakStmt, *ContinueStmt, *BadStmt:
+
+	// Expressions
+	case *Ident, *IntLit, *StringLit, *KeywordLit, *BadExpr:
+	case *ParenExpr:
+		n.X = r.expr(n.X)
+	case *UnaryExpr:
+		n.X = r.expr(n.X)
+	case *BinaryExpr:
+		n.X = r.expr(n.X)
+		n.Y = r.expr(n.Y)
+	case *IndexExpr:
+		n.X = r.ident(n.X)
+		n.Index = r.expr(n.Index)
+	case *CallExpr:
+		if n.Receiver != nil {
+			n.Receiver = r.ident(n.Receiver)
+		}
+		n.Name = r.ident(n.Name)
+		for i, arg := range n.Args {
+			n.Args[i] = r.expr(arg)
+		}
+	case *SelectorExpr:
+		n.X = r.ident(n.X)
+		n.Sel = r.ident(n.Sel)
+
+	default:
+		panic(fmt.Sprintf("jack_ast.Rewrite: unexpected node type %T", n))
+	}
+
+	return r(node)
+}
+
+// rewriteAs rewrites node and checks that its replacement is a T.
+func rewriteAs[T Node](r rewriter, node T) T {
+	replaced := r.node(node)
+	t, ok := replaced.(T)
+	if !ok {
+		panic(fmt.Sprintf("jack_ast.Rewrite: cannot replace %T with %T", node, replaced))
+	}
+	return t
+}
+
+func (r rewriter) ident(x *Ident) *Ident { return rewriteAs[*Ident](r, x) }
+func (r rewriter) expr(x Expr) Expr      { return rewriteAs[Expr](r, x) }
+
+func (r rewriter) idents(list []*Ident) {
+	for i, x := range list {
+		list[i] = r.ident(x)
+	}
+}
+
+func (r rewriter) stmts(list []Stmt) {
+	for i, x := range list {
+		list[i] = rewriteAs[Stmt](r, x)
+	}
+}
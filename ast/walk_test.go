@@ -0,0 +1,146 @@
+package jack_ast_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	jack_ast "github.com/renojcpp/n2t-compiler/ast"
+	jack_parser "github.com/renojcpp/n2t-compiler/parser"
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+)
+
+const src = "class Main {\n  function int f(int a) {\n    if (a) { let a = a + (1 + 2); }\n    return Math.max(a, 3 * 4);\n  }\n}\n"
+
+func parse(t *testing.T) *jack_ast.Class {
+	class, err := jack_parser.ParseFile(jack_tokenizer.NewLexer(strings.NewReader(src)))
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+	return class
+}
+
+func TestInspect(t *testing.T) {
+	var names []string
+	jack_ast.Inspect(parse(t), func(n jack_ast.Node) bool {
+		if id, ok := n.(*jack_ast.Ident); ok {
+			names = append(names, id.Name)
+		}
+		// leave out the arguments of calls
+		_, ok := n.(*jack_ast.CallExpr)
+		return !ok
+	})
+
+	if got, want := strings.Join(names, " "), "Main f a a a a"; got != want {
+		t.Errorf("got %s, wanted %s", got, want)
+	}
+}
+
+type depthVisitor struct {
+	depth, max *int
+}
+
+func (v depthVisitor) Visit(n jack_ast.Node) jack_ast.Visitor {
+	if n == nil {
+		*v.depth--
+		return nil
+	}
+	*v.depth++
+	if *v.depth > *v.max {
+		*v.max = *v.depth
+	}
+	return v
+}
+
+func TestWalk(t *testing.T) {
+	var depth, max int
+	jack_ast.Walk(depthVisitor{&depth, &max}, parse(t))
+
+	// Class, SubroutineDec, SubroutineBody, IfStmt, Block, LetStmt,
+	// BinaryExpr, ParenExpr, BinaryExpr, IntLit
+	if depth != 0 || max != 10 {
+		t.Errorf("got depth %d and maximum %d, wanted 0 and 10", depth, max)
+	}
+}
+
+// fold replaces sums and products of two integer constants with their
+// value.
+func fold(n jack_ast.Node) jack_ast.Node {
+	if paren, ok := n.(*jack_ast.ParenExpr); ok {
+		if lit, ok := paren.X.(*jack_ast.IntLit); ok {
+			return lit
+		}
+	}
+
+	bin, ok := n.(*jack_ast.BinaryExpr)
+	if !ok {
+		return n
+	}
+	x, ok1 := bin.X.(*jack_ast.IntLit)
+	y, ok2 := bin.Y.(*jack_ast.IntLit)
+	if !ok1 || !ok2 {
+		return n
+	}
+
+	a, _ := strconv.Atoi(x.Value)
+	b, _ := strconv.Atoi(y.Value)
+	switch bin.Op {
+	case jack_tokenizer.SYM_PLUS:
+		a += b
+	case jack_tokenizer.SYM_ASTERISK:
+		a *= b
+	default:
+		return n
+	}
+
+	return &jack_ast.IntLit{ValuePos: x.ValuePos, Value: strconv.Itoa(a), Raw: strconv.Itoa(a)}
+}
+
+func TestRewrite(t *testing.T) {
+	class := parse(t)
+	if got := jack_ast.Rewrite(class, fold); got != class {
+		t.Fatalf("got %T, wanted the class itself", got)
+	}
+
+	body := class.Subroutines[0].Body.Stmts
+	let := body[0].(*jack_ast.IfStmt).Body.Stmts[0].(*jack_ast.LetStmt)
+	call := body[1].(*jack_ast.ReturnStmt).Value.(*jack_ast.CallExpr)
+
+	tests := []struct {
+		node jack_ast.Expr
+		want string
+		pos  string
+	}{
+		{let.Value.(*jack_ast.BinaryExpr).Y, "3", "3:27"},
+		{call.Args[1], "12", "4:24"},
+	}
+	for _, tt := range tests {
+		lit, ok := tt.node.(*jack_ast.IntLit)
+		if !ok || lit.Value != tt.want {
+			t.Errorf("got %#v, wanted %s", tt.node, tt.want)
+			continue
+		}
+		if got := lit.Pos().String(); got != tt.pos {
+			t.Errorf("%s: got position %s, wanted %s", tt.want, got, tt.pos)
+		}
+	}
+
+	if got := let.Pos().String(); got != "3:14" {
+		t.Errorf("let: got position %s, wanted 3:14", got)
+	}
+}
+
+func TestRewritePanicsOnMisfit(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("got no panic, wanted one for a statement in place of an expression")
+		}
+	}()
+
+	jack_ast.Rewrite(parse(t), func(n jack_ast.Node) jack_ast.Node {
+		if _, ok := n.(*jack_ast.IntLit); ok {
+			return &jack_ast.BadStmt{}
+		}
+		return n
+	})
+}
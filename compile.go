@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	jack_ast "github.com/renojcpp/n2t-compiler/ast"
+	jack_compiler "github.com/renojcpp/n2t-compiler/compiler"
+	jack_parser "github.com/renojcpp/n2t-compiler/parser"
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+	jack_types "github.com/renojcpp/n2t-compiler/types"
+)
+
+func runCompile(args []string) int {
+	fs := flag.NewFlagSet("compile", flag.ExitOnError)
+	options := lexerFlags(fs)
+	parserOptions := parserFlags(fs)
+	strict := fs.Bool("strict", false, "make loose uses of ints, such as an int used as a boolean, errors instead of warnings")
+	fs.Parse(args)
+
+	files, err := jackFiles(fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	// every class of the program is parsed before any is compiled, so
+	// that calls between them can be checked
+	all, err := programFiles(files)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	status := 0
+	var names []string
+	var classes, compiled []*jack_ast.Class
+	for i, name := range all {
+		input := i < len(files)
+		file, err := os.Open(name)
+		if err != nil {
+			if input {
+				fmt.Fprintf(os.Stderr, "failed to open file: %s\n", name)
+				status = 1
+			}
+			continue
+		}
+
+		lexer := jack_tokenizer.NewLexer(file, options(filepath.Base(name))...)
+		parser := jack_parser.NewParser(lexer, parserOptions()...)
+		class, err := parser.Parse()
+		file.Close()
+		if class != nil {
+			classes = append(classes, class)
+		}
+		if !input {
+			continue
+		}
+
+		reportWarnings(parser.Diagnostics())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			status = 1
+			continue
+		}
+		names = append(names, name)
+		compiled = append(compiled, class)
+	}
+
+	var typeOptions []jack_types.Option
+	if *strict {
+		typeOptions = append(typeOptions, jack_types.WithStrict())
+	}
+	checker := jack_types.NewChecker(classes, typeOptions...)
+	program := jack_compiler.NewProgram(classes...)
+	for i, class := range compiled {
+		diags := checker.Check(class)
+		for _, diag := range diags {
+			fmt.Fprintln(os.Stderr, diag)
+		}
+		if diags.HasErrors() {
+			status = 1
+			continue
+		}
+
+		fmt.Println("outputting")
+		f, _ := os.Create(names[i] + ".vm")
+		err := program.Compile(class, f)
+		f.Close()
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			status = 1
+		}
+	}
+
+	return status
+}
+
+// programFiles returns files followed by the other .jack files in their
+// directories, which make up the rest of the program.
+func programFiles(files []string) ([]string, error) {
+	seen := make(map[string]bool, len(files))
+	for _, name := range files {
+		seen[filepath.Clean(name)] = true
+	}
+
+	all := append([]string(nil), files...)
+	for _, name := range files {
+		dir := filepath.Dir(name)
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+
+		siblings, err := jackFiles([]string{dir})
+		if err != nil {
+			return nil, err
+		}
+		for _, sibling := range siblings {
+			if !seen[filepath.Clean(sibling)] {
+				seen[filepath.Clean(sibling)] = true
+				all = append(all, sibling)
+			}
+		}
+	}
+
+	return all, nil
+}
@@ -0,0 +1,52 @@
+package jack_compiler
+
+import (
+	"strings"
+
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+)
+
+const (
+	CodeOutsideCharset    jack_tokenizer.Code = "outside-charset"
+	CodeIntegerOutOfRange jack_tokenizer.Code = "integer-out-of-range"
+)
+
+// MAX_INT is the largest integer constant Jack accepts.
+const MAX_INT = 32767
+
+// Hack character codes for keys that have no printable glyph.
+const (
+	HACK_NEWLINE = 128
+	HACK_LAST    = 152 // F12
+)
+
+// hackCharacter maps a byte of a decoded string constant onto the Hack
+// character set. Printable ASCII maps to itself, a newline to the Hack
+// newline key and a tab, which Hack has no glyph for, to a space. Codes
+// 128 to 152 can only come from \u{NNN} escapes and are passed through.
+// A tab is only mapped for the \t escape; rawTab reports one typed
+// straight into the source.
+func hackCharacter(b byte) (int, bool) {
+	switch {
+	case b == '\n':
+		return HACK_NEWLINE, true
+	case b == '\t':
+		return ' ', true
+	case b >= ' ' && b <= '~':
+		return int(b), true
+	case b >= HACK_NEWLINE && b <= HACK_LAST:
+		return int(b), true
+	default:
+		return 0, false
+	}
+}
+
+// rawTab reports a tab byte written as is in raw, the source text of a
+// string constant or character literal starting at pos.
+func (d *diagnostics) rawTab(pos jack_tokenizer.Position, raw, what string) {
+	if i := strings.IndexByte(raw, '\t'); i >= 0 {
+		pos.Column += i
+		pos.Offset += i
+		d.errorf(pos, CodeOutsideCharset, "character %d in %s is outside the Hack character set", '\t', what)
+	}
+}
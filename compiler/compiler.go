@@ -1,38 +1,33 @@
 package jack_compiler
 
 import (
-	"errors"
 	"fmt"
 	"io"
 	"strconv"
 	"strings"
 
+	jack_ast "github.com/renojcpp/n2t-compiler/ast"
+	jack_parser "github.com/renojcpp/n2t-compiler/parser"
 	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
 )
 
-type tokenpair struct {
-	tt jack_tokenizer.TokenType
-	st jack_tokenizer.TokenSubtype
-}
-
-var typePair = []tokenpair{
-	{jack_tokenizer.KEYWORD, jack_tokenizer.KW_INT},
-	{jack_tokenizer.KEYWORD, jack_tokenizer.KW_CHAR},
-	{jack_tokenizer.KEYWORD, jack_tokenizer.KW_BOOLEAN},
-	{jack_tokenizer.IDENTIFIER, jack_tokenizer.NONE},
-}
-
-type parser struct {
-	tokens []jack_tokenizer.Token
-	index  int
-
-	err          error
+// compiler walks the syntax tree of a class and writes out its VM code.
+type compiler struct {
+	diagnostics
+	program      *Program
 	subroutineSt *SymbolTable
 	classSt      *SymbolTable
 	vmWriter     VMWriter
 
 	className   string
+	subroutine  *jack_ast.SubroutineDec // the subroutine being compiled
 	labelNumber int
+	loops       []loopLabels // the loops around the statement being compiled, innermost last
+}
+
+// loopLabels are where break and continue jump to in a loop.
+type loopLabels struct {
+	brk, cont string
 }
 
 type symboldata struct {
@@ -41,20 +36,21 @@ type symboldata struct {
 	index  int
 }
 
-func NewParser(tokens []jack_tokenizer.Token, vmw VMWriter) *parser {
-	return &parser{
-		tokens,
-		0,
-		nil,
+func newCompiler(program *Program, vmw VMWriter) *compiler {
+	return &compiler{
+		diagnostics{},
+		program,
 		NewSymbolTable(),
 		NewSymbolTable(),
 		vmw,
 		"",
+		nil,
 		0,
+		nil,
 	}
 }
 
-func (s *parser) resolveSymbol(sym string) symboldata {
+func (s *compiler) resolveSymbol(sym string) symboldata {
 	res := s.subroutineSt.KindOf(Name(sym))
 
 	if res == NONE {
@@ -81,628 +77,469 @@ func (s *parser) resolveSymbol(sym string) symboldata {
 	}
 }
 
-func (s *parser) process(pairs []tokenpair) (*jack_tokenizer.Token, error) {
-	var err error
-	if !s.matches(pairs) {
-		var ss strings.Builder
-		for _, p := range pairs {
-			ss.WriteString(fmt.Sprintf("{ %s, %s }", p.tt.String(), p.st.String()))
-		}
-
-		s.err = fmt.Errorf("%s %s %s %s: grammar error: got %s, wanted %s @ %d", s.tokens[s.index-2].Lexeme, s.tokens[s.index-1].Lexeme, s.tokens[s.index].Lexeme, s.tokens[s.index+1].Lexeme, s.Current().Lexeme, ss.String(), s.index)
-		err = s.err
-	}
-	ret := s.Current()
-	s.Advance()
-
-	return ret, err
-}
-
-func (s *parser) matches(pairs []tokenpair) bool {
-	curr := s.Current()
-	for _, p := range pairs {
-		if p.tt == curr.Tokentype && p.st == curr.Subtype {
-			return true
-		}
-	}
-
-	return false
-}
-
-func (s *parser) atEnd() bool {
-	return s.index >= len(s.tokens)
-}
-
-func (s *parser) peek() (*jack_tokenizer.Token, error) {
-	if s.atEnd() || s.index+1 >= len(s.tokens) {
-		return nil, errors.New("error at end")
+// typeOf returns the declared type of a resolved symbol.
+func (s *compiler) typeOf(sym symboldata) string {
+	if s.subroutineSt.KindOf(Name(sym.name)) != NONE {
+		return s.subroutineSt.TypeOf(Name(sym.name))
 	}
 
-	return &s.tokens[s.index+1], nil
-}
-
-func (s *parser) Current() *jack_tokenizer.Token {
-	return &s.tokens[s.index]
-}
-
-func (s *parser) Advance() {
-	s.index++
+	return s.classSt.TypeOf(Name(sym.name))
 }
 
-func (s *parser) Parse() error {
-	s.Class()
-
-	return s.err
-}
-
-func (s *parser) helper_type(additional []tokenpair) (*jack_tokenizer.Token, error) {
-	tp := make([]tokenpair, 0)
-	tp = append(tp, typePair...)
-	tp = append(tp, additional...)
-
-	return s.process(tp)
-}
-
-func (s *parser) symbolHelper(st jack_tokenizer.TokenSubtype) (*jack_tokenizer.Token, error) {
-	return s.process([]tokenpair{
-		{jack_tokenizer.SYMBOL, st},
-	})
-}
-
-func (s *parser) identifierHelper() (*jack_tokenizer.Token, error) {
-	return s.process([]tokenpair{
-		{jack_tokenizer.IDENTIFIER, jack_tokenizer.NONE},
-	})
-}
+// label returns a label no other statement of the class uses.
+func (s *compiler) label(kind string) string {
+	label := fmt.Sprintf("%s.%s-%d", s.className, kind, s.labelNumber)
+	s.labelNumber++
 
-func (s *parser) keywordHelper(st jack_tokenizer.TokenSubtype) (*jack_tokenizer.Token, error) {
-	return s.process([]tokenpair{
-		{jack_tokenizer.KEYWORD, st},
-	})
+	return label
 }
 
 // compiles a Class
-func (s *parser) Class() {
+func (s *compiler) Class(class *jack_ast.Class) {
 	s.classSt.Reset()
 	s.subroutineSt.Reset()
+	s.className = class.Name.Name
 
-	s.keywordHelper(jack_tokenizer.KW_CLASS)
-	token, err := s.identifierHelper()
-	if err == nil {
-		s.className = token.Lexeme
-	}
-	s.symbolHelper(jack_tokenizer.SYM_LEFT_BRACE)
-
-	for s.matches([]tokenpair{
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_STATIC},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_FIELD},
-	}) {
-		s.ClassVarDec()
+	for _, dec := range class.Vars {
+		for _, name := range dec.Names {
+			s.define(s.classSt, name, dec.Type.Name, constructorTTtoFT[dec.Keyword])
+		}
 	}
 
-	for s.matches([]tokenpair{
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_CONSTRUCTOR},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_METHOD},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_FUNCTION},
-	}) {
-		s.Subroutine()
+	for _, dec := range class.Subroutines {
+		s.Subroutine(dec)
 	}
-
-	s.symbolHelper(jack_tokenizer.SYM_RIGHT_BRACE)
 }
 
-// Compiles a static variable declaration or a field declaration
-func (s *parser) ClassVarDec() {
-	var ft FieldType
-	var typing string
-	var varName string
-	hasError := false
-	token, err := s.process([]tokenpair{
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_STATIC},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_FIELD},
-	})
-
-	if err == nil {
-		ft = constructorTTtoFT[token.Subtype]
-	} else {
-		hasError = true
-	}
-
-	token, err = s.helper_type([]tokenpair{})
-
-	if err == nil {
-		typing = token.Lexeme
-	} else {
-		hasError = true
+// define adds a variable to st, reporting a name declared twice.
+func (s *compiler) define(st *SymbolTable, name *jack_ast.Ident, typ string, kind FieldType) {
+	if err := st.Define(Name(name.Name), typ, kind); err != nil {
+		s.errorf(name.Pos(), CodeRedeclared, "%s", err)
 	}
-
-	token, err = s.identifierHelper()
-
-	if err == nil {
-		varName = token.Lexeme
-	} else {
-		hasError = true
-	}
-
-	if !hasError {
-		s.classSt.Define(Name(varName), typing, ft)
-	}
-
-	// (',' varName)
-	for s.matches([]tokenpair{{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_COMMA}}) {
-		s.symbolHelper(jack_tokenizer.SYM_COMMA)
-		token, err = s.identifierHelper()
-
-		if err == nil {
-			varName = token.Lexeme
-			s.classSt.Define(Name(varName), typing, ft)
-		}
-	}
-
-	s.symbolHelper(jack_tokenizer.SYM_SEMICOLON)
 }
 
 // Compiles a complete method, function or constructor
-func (s *parser) Subroutine() {
+func (s *compiler) Subroutine(dec *jack_ast.SubroutineDec) {
 	s.subroutineSt.Reset()
+	s.subroutine = dec
 
-	keyToken, err := s.process([]tokenpair{
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_CONSTRUCTOR},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_FUNCTION},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_METHOD},
-	})
-
-	if err == nil && keyToken.Subtype == jack_tokenizer.KW_METHOD {
+	if dec.Keyword == jack_tokenizer.KW_METHOD {
 		s.subroutineSt.Define("this", s.className, ARG)
 	}
+	for _, param := range dec.Params {
+		s.define(s.subroutineSt, param.Name, param.Type.Name, ARG)
+	}
+	for _, vars := range dec.Body.Vars {
+		for _, name := range vars.Names {
+			s.define(s.subroutineSt, name, vars.Type.Name, VAR)
+		}
+	}
 
-	s.helper_type([]tokenpair{
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_VOID},
-	})
-
-	token, _ := s.identifierHelper()
-	s.symbolHelper(jack_tokenizer.SYM_LEFT_PAREN)
-
-	nargs := s.Parameters()
-
-	s.vmWriter.WriteFunction(Name(fmt.Sprintf("%s.%s", s.className, token.Lexeme)), nargs)
+	s.vmWriter.WriteFunction(Name(fmt.Sprintf("%s.%s", s.className, dec.Name.Name)), s.subroutineSt.VarCount(VAR))
 
-	if keyToken.Subtype == jack_tokenizer.KW_METHOD {
+	if dec.Keyword == jack_tokenizer.KW_METHOD {
 		s.vmWriter.WritePush(ARGUMENT, 0)
 		s.vmWriter.WritePop(POINTER, 0)
-	} else if keyToken.Subtype == jack_tokenizer.KW_CONSTRUCTOR {
-		n := s.subroutineSt.VarCount(FIELD)
+	} else if dec.Keyword == jack_tokenizer.KW_CONSTRUCTOR {
+		n := s.classSt.VarCount(FIELD)
 		s.vmWriter.WritePush(CONSTANT, n)
 		s.vmWriter.WriteCall("Memory.alloc", 1)
 		s.vmWriter.WritePop(POINTER, 0)
 	}
 
-	s.symbolHelper(jack_tokenizer.SYM_RIGHT_PAREN)
-
-	s.SubroutineBody()
-}
-
-// Compiles a (possibly empty) Parameters
-// list. Does not handle the enclosing
-// parantheses tokens (ands).
-func (s *parser) Parameters() int {
-	count := 0
-	processTypeVarName := func() {
-		var typing string
-		var varName string
-		token, err := s.process(typePair)
-		if err == nil {
-			typing = token.Lexeme
-		}
-		token, err = s.identifierHelper()
-
-		if err == nil {
-			varName = token.Lexeme
-		}
-
-		s.subroutineSt.Define(Name(varName), typing, ARG)
-		count++
-	}
-	if s.matches(typePair) {
-		processTypeVarName()
-
-		for s.matches([]tokenpair{
-			{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_COMMA},
-		}) {
-			s.symbolHelper(jack_tokenizer.SYM_COMMA)
-			processTypeVarName()
+	s.Statements(dec.Body.Stmts)
+	if !terminates(dec.Body.Stmts) {
+		if dec.Return.Keyword == jack_tokenizer.KW_VOID && dec.Keyword != jack_tokenizer.KW_CONSTRUCTOR {
+			// running off the end of a void subroutine returns
+			s.vmWriter.WritePush(CONSTANT, 0)
+			s.vmWriter.WriteReturn()
+		} else {
+			s.errorf(dec.Body.Rbrace, CodeMissingReturn, "missing return at end of %s.%s", s.className, dec.Name.Name)
 		}
 	}
-
-	return count
-}
-
-// Compiles a subroutine's body
-func (s *parser) SubroutineBody() {
-	s.symbolHelper(jack_tokenizer.SYM_LEFT_BRACE)
-
-	for s.matches([]tokenpair{
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_VAR},
-	}) {
-		s.VarDec()
-	}
-	s.Statements()
-}
-
-// Compiles a var declaration
-func (s *parser) VarDec() {
-	s.keywordHelper(jack_tokenizer.KW_VAR)
-	var typing string
-	var varName string
-
-	token, err := s.helper_type(nil)
-	if err == nil {
-		typing = token.Lexeme
-	}
-
-	token, err = s.identifierHelper()
-
-	if err == nil {
-		varName = token.Lexeme
-	}
-
-	s.subroutineSt.Define(Name(varName), typing, FieldType(VAR))
-	for s.matches([]tokenpair{
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_COMMA},
-	}) {
-		s.symbolHelper(jack_tokenizer.SYM_COMMA)
-		s.identifierHelper()
-	}
-
-	s.symbolHelper(jack_tokenizer.SYM_SEMICOLON)
 }
 
 // Compiles a sequeneces of statemnents
-// Does not handle the enclosing curly
-// bracket tokens { and }.
-func (s *parser) Statements() {
-	states := []tokenpair{
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_LET},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_IF},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_WHILE},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_DO},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_RETURN},
-	}
-
-	if s.matches(states) {
-		for s.matches(states) {
-			switch {
-			case s.matches([]tokenpair{states[0]}):
-				s.LetStatement()
-			case s.matches([]tokenpair{states[1]}):
-				s.IfStatement()
-			case s.matches([]tokenpair{states[2]}):
-				s.While()
-			case s.matches([]tokenpair{states[3]}):
-				s.Do()
-			case s.matches([]tokenpair{states[4]}):
-				s.ReturnStatement()
-			}
+func (s *compiler) Statements(stmts []jack_ast.Stmt) {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *jack_ast.LetStmt:
+			s.LetStatement(stmt)
+		case *jack_ast.IfStmt:
+			s.IfStatement(stmt)
+		case *jack_ast.WhileStmt:
+			s.While(stmt)
+		case *jack_ast.DoStmt:
+			s.Do(stmt)
+		case *jack_ast.ReturnStmt:
+			s.ReturnStatement(stmt)
+		case *jack_ast.ForStmt:
+			s.ForStatement(stmt)
+		case *jack_ast.BreakStmt:
+			// goto the end of the loop
+			s.vmWriter.WriteGoto(s.loops[len(s.loops)-1].brk)
+		case *jack_ast.ContinueStmt:
+			// goto the next pass of the loop
+			s.vmWriter.WriteGoto(s.loops[len(s.loops)-1].cont)
+		case *jack_ast.Block:
+			s.Statements(stmt.Stmts)
 		}
-	} else {
-		s.err = errors.New("unexpected lexeme " + s.Current().Lexeme)
 	}
 }
 
 // Compiles a let statement.
-func (s *parser) LetStatement() {
-	s.keywordHelper(jack_tokenizer.KW_LET)
-	token, _ := s.identifierHelper()
-	res := s.resolveSymbol(token.Lexeme)
-	isarr := false
-	if s.matches([]tokenpair{
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_LEFT_BRACK},
-	}) {
-		isarr = true
-		s.symbolHelper(jack_tokenizer.SYM_LEFT_BRACK)
+func (s *compiler) LetStatement(stmt *jack_ast.LetStmt) {
+	res := s.variable(stmt.Name)
+	op, compound := stmt.Assign.AssignOp()
 
-		s.vmWriter.WritePush(fieldtoSegment[res.symbol], res.index)
-		s.Expression()
-		s.vmWriter.WriteArithmetic(ADD)
-		s.symbolHelper(jack_tokenizer.SYM_RIGHT_BRACK)
+	if _, _, ok := s.program.constant(s.className, stmt.Name.Name); ok && res.symbol == NONE {
+		s.errorf(stmt.Name.Pos(), CodeAssignToConstant, "cannot assign to constant %s", stmt.Name.Name)
+		return
+	}
+	if res.symbol == NONE {
+		s.undefined(stmt.Name, false)
+		return
 	}
 
-	s.symbolHelper(jack_tokenizer.SYM_EQUALS)
+	if stmt.Index != nil {
+		s.vmWriter.WritePush(fieldtoSegment[res.symbol], res.index)
+		s.Expression(stmt.Index)
+		s.vmWriter.WriteArithmetic(ADD)
 
-	s.Expression()
-	if isarr {
+		if compound {
+			// keep a copy of the address on the stack, where the
+			// value cannot clobber it, and read the element through
+			// the other
+			s.vmWriter.WritePop(TEMP, 0)
+			s.vmWriter.WritePush(TEMP, 0)
+			s.vmWriter.WritePush(TEMP, 0)
+			s.vmWriter.WritePop(POINTER, 1)
+			s.vmWriter.WritePush(THAT, 0)
+			s.Expression(stmt.Value)
+			s.binaryOp(op)
+		} else {
+			s.Expression(stmt.Value)
+		}
 		s.vmWriter.WritePop(TEMP, 0)
 		s.vmWriter.WritePop(POINTER, 1)
 		s.vmWriter.WritePush(TEMP, 0)
 		s.vmWriter.WritePop(THAT, 0)
-	} else {
-		// pop symbolArgName index
+		return
+	}
 
-		s.vmWriter.WritePop(fieldtoSegment[res.symbol], res.index)
+	if compound {
+		s.vmWriter.WritePush(fieldtoSegment[res.symbol], res.index)
+		s.Expression(stmt.Value)
+		s.binaryOp(op)
+	} else {
+		s.Expression(stmt.Value)
 	}
-	s.symbolHelper(jack_tokenizer.SYM_SEMICOLON)
+	// pop symbolArgName index
+	s.vmWriter.WritePop(fieldtoSegment[res.symbol], res.index)
 }
 
 // Compiles an if statement
 // possibly with a trailing else clause
-func (s *parser) IfStatement() {
-	s.keywordHelper(jack_tokenizer.KW_IF)
+func (s *compiler) IfStatement(stmt *jack_ast.IfStmt) {
+	elseLabel, endLabel := s.label("IF"), s.label("IF")
 
-	s.symbolHelper(jack_tokenizer.SYM_LEFT_PAREN)
-
-	s.Expression()
+	s.Expression(stmt.Cond)
 	// not
 	s.vmWriter.WriteArithmetic(NOT)
 	// if-goto label1
-	s.vmWriter.WriteIf(fmt.Sprintf("%s.IF-%d", s.className, s.labelNumber))
-	s.labelNumber++
-	s.symbolHelper(jack_tokenizer.SYM_RIGHT_PAREN)
-
-	s.symbolHelper(jack_tokenizer.SYM_LEFT_BRACE)
-
-	s.Statements()
+	s.vmWriter.WriteIf(elseLabel)
+	s.Statements(stmt.Body.Stmts)
 	// goto label2
-	s.vmWriter.WriteGoto(fmt.Sprintf("%s.IF-%d", s.className, s.labelNumber))
-	s.symbolHelper(jack_tokenizer.SYM_RIGHT_BRACE)
-
-	if s.matches([]tokenpair{
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_ELSE},
-	}) {
-		// label l1
-		s.vmWriter.WriteLabel(fmt.Sprintf("%s.IF-%d", s.className, s.labelNumber-1))
-		s.symbolHelper(jack_tokenizer.SYM_LEFT_BRACE)
-
-		s.Statements()
+	s.vmWriter.WriteGoto(endLabel)
 
-		s.symbolHelper(jack_tokenizer.SYM_RIGHT_BRACE)
+	// label l1
+	s.vmWriter.WriteLabel(elseLabel)
+	if stmt.Else != nil {
+		s.Statements([]jack_ast.Stmt{stmt.Else})
 	}
 	// label l2
-	s.vmWriter.WriteLabel(fmt.Sprintf("%s.IF-%d", s.className, s.labelNumber))
+	s.vmWriter.WriteLabel(endLabel)
 }
 
 // Compiles a While statement
-func (s *parser) While() {
-	s.keywordHelper(jack_tokenizer.KW_WHILE)
-	s.symbolHelper(jack_tokenizer.SYM_LEFT_PAREN)
-	s.vmWriter.WriteLabel(fmt.Sprintf("%s-IF-%d", s.className, s.labelNumber))
-	s.Expression()
+func (s *compiler) While(stmt *jack_ast.WhileStmt) {
+	loopLabel, endLabel := s.label("WHILE"), s.label("WHILE")
+
+	s.vmWriter.WriteLabel(loopLabel)
+	s.Expression(stmt.Cond)
 	// not
 	s.vmWriter.WriteArithmetic(NOT)
-	s.labelNumber++
 	// if-goto l2
-	s.vmWriter.WriteIf(fmt.Sprintf("%s-IF-%d", s.className, s.labelNumber))
-	s.symbolHelper(jack_tokenizer.SYM_RIGHT_PAREN)
-	s.symbolHelper(jack_tokenizer.SYM_LEFT_BRACE)
-	s.Statements()
+	s.vmWriter.WriteIf(endLabel)
+	s.loopBody(stmt.Body, loopLabels{endLabel, loopLabel})
 	// goto l1
-	s.vmWriter.WriteGoto(fmt.Sprintf("%s-IF-%d", s.className, s.labelNumber-1))
-	s.symbolHelper(jack_tokenizer.SYM_RIGHT_BRACE)
+	s.vmWriter.WriteGoto(loopLabel)
+	// label l2
+	s.vmWriter.WriteLabel(endLabel)
+}
+
+// Compiles a for statement, as the while loop
+//
+//	init
+//	while (cond) { body post }
+//
+// where continue goes to post.
+func (s *compiler) ForStatement(stmt *jack_ast.ForStmt) {
+	loopLabel, postLabel, endLabel := s.label("FOR"), s.label("FOR"), s.label("FOR")
+
+	if stmt.Init != nil {
+		s.Statements([]jack_ast.Stmt{stmt.Init})
+	}
+	// label l1
+	s.vmWriter.WriteLabel(loopLabel)
+	if stmt.Cond != nil {
+		s.Expression(stmt.Cond)
+		// not
+		s.vmWriter.WriteArithmetic(NOT)
+		// if-goto l3
+		s.vmWriter.WriteIf(endLabel)
+	}
+	s.loopBody(stmt.Body, loopLabels{endLabel, postLabel})
 	// label l2
-	s.vmWriter.WriteLabel(fmt.Sprintf("%s-IF-%d", s.className, s.labelNumber))
+	s.vmWriter.WriteLabel(postLabel)
+	if stmt.Post != nil {
+		s.Statements([]jack_ast.Stmt{stmt.Post})
+	}
+	// goto l1
+	s.vmWriter.WriteGoto(loopLabel)
+	// label l3
+	s.vmWriter.WriteLabel(endLabel)
+}
+
+// loopBody compiles the body of a loop, with break and continue going
+// to the labels given.
+func (s *compiler) loopBody(body *jack_ast.Block, labels loopLabels) {
+	s.loops = append(s.loops, labels)
+	s.Statements(body.Stmts)
+	s.loops = s.loops[:len(s.loops)-1]
 }
 
 // Compiles a Do statement
-func (s *parser) Do() {
-	s.keywordHelper(jack_tokenizer.KW_DO)
-	s.Expression()
+func (s *compiler) Do(stmt *jack_ast.DoStmt) {
+	s.SubroutineCall(stmt.Call)
 	// pop something 0
 	s.vmWriter.WritePop(TEMP, 0)
-	s.symbolHelper(jack_tokenizer.SYM_SEMICOLON)
 }
 
-// Compiles a return statement
-func (s *parser) ReturnStatement() {
-	begTerm := []tokenpair{
-		{jack_tokenizer.STRING_CONSTANT, jack_tokenizer.NONE},
-		{jack_tokenizer.INT_CONSTANT, jack_tokenizer.NONE},
-		{jack_tokenizer.IDENTIFIER, jack_tokenizer.NONE},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_PLUS},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_LEFT_PAREN},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_MINUS},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_TILDE},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_TRUE},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_FALSE},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_NULL},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_THIS},
+// Compiles a return statement. A void subroutine returns 0, which the
+// caller throws away.
+func (s *compiler) ReturnStatement(stmt *jack_ast.ReturnStmt) {
+	name := fmt.Sprintf("%s.%s", s.className, s.subroutine.Name.Name)
+	void := s.subroutine.Return.Keyword == jack_tokenizer.KW_VOID
+	switch {
+	case s.subroutine.Keyword == jack_tokenizer.KW_CONSTRUCTOR:
+		if stmt.Value == nil || !isThis(stmt.Value) {
+			s.errorf(stmt.Pos(), CodeConstructorReturn, "constructor %s must return this", name)
+		}
+	case void && stmt.Value != nil:
+		s.errorf(stmt.Value.Pos(), CodeReturnValue, "%s is void and cannot return a value", name)
+	case !void && stmt.Value == nil:
+		s.errorf(stmt.Pos(), CodeReturnValue, "%s must return a value of type %s", name, s.subroutine.Return.Name)
 	}
 
-	s.keywordHelper(jack_tokenizer.KW_RETURN)
-	if s.matches(begTerm) {
-		s.Expression()
+	if stmt.Value != nil {
+		s.Expression(stmt.Value)
+	} else {
+		s.vmWriter.WritePush(CONSTANT, 0)
 	}
 	// return
 	s.vmWriter.WriteReturn()
-	s.symbolHelper(jack_tokenizer.SYM_SEMICOLON)
 }
 
-// Compiles an Expression
-func (s *parser) Expression() {
-	op := []tokenpair{
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_PLUS},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_MINUS},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_ASTERISK},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_SLASH},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_ASTERISK},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_PIPE},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_LESS_THAN},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_GREATER_THAN},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_EQUALS},
-	}
-	s.Term()
-
-	for s.matches(op) {
-		token, _ := s.process(op)
-		s.Term()
-
-		switch token.Subtype {
-		case jack_tokenizer.SYM_SLASH:
-			s.vmWriter.WriteCall("Math.divide", 2)
-		case jack_tokenizer.SYM_ASTERISK:
-			s.vmWriter.WriteCall("Math.multiply", 2)
-		default:
-			s.vmWriter.WriteArithmetic(subtypeToOp[token.Subtype])
-		}
-
+// binaryOp applies the binary operator op to the top two values on the
+// stack.
+func (s *compiler) binaryOp(op jack_tokenizer.TokenSubtype) {
+	switch op {
+	case jack_tokenizer.SYM_SLASH:
+		s.vmWriter.WriteCall("Math.divide", 2)
+	case jack_tokenizer.SYM_ASTERISK:
+		s.vmWriter.WriteCall("Math.multiply", 2)
+	default:
+		s.vmWriter.WriteArithmetic(subtypeToOp[op])
 	}
 }
 
-// Compiles a Term. If the current token is an
-// identifier, the routine must resolve it
-// into a variable, an array element, or a
-// subroutine call. A single lookahead tokezn,
-// which may be [, (, or ., suffices to distinguish
-// between the possibilities.
-// Any other token is not part of this Term
-// and should not be advanced over.
-func (s *parser) Term() {
-	switch s.Current().Tokentype {
-	case jack_tokenizer.IDENTIFIER:
-		// variable, array element or subroutine
-		peek, err := s.peek()
-		if err != nil {
-			s.err = nil
-		} else {
-			switch peek.Subtype {
-			case jack_tokenizer.SYM_LEFT_PAREN:
-			case jack_tokenizer.SYM_PERIOD:
-				s.SubroutineCall()
-			case jack_tokenizer.SYM_LEFT_BRACK:
-				// varname[expression]
-				token, _ := s.identifierHelper()
-				resolved := s.resolveSymbol(token.Lexeme)
-				s.vmWriter.WritePush(fieldtoSegment[resolved.symbol], resolved.index)
-				s.symbolHelper(jack_tokenizer.SYM_LEFT_BRACK)
-				s.Expression()
-				s.vmWriter.WriteArithmetic(ADD)
-				s.symbolHelper(jack_tokenizer.SYM_RIGHT_BRACK)
-				s.vmWriter.WritePop(POINTER, 1)
-				s.vmWriter.WritePush(THAT, 0)
-			default:
-				token, _ := s.identifierHelper()
-				resolved := s.resolveSymbol(token.Lexeme)
-
-				s.vmWriter.WritePush(fieldtoSegment[resolved.symbol], resolved.index)
+// Compiles an Expression
+func (s *compiler) Expression(expr jack_ast.Expr) {
+	switch expr := expr.(type) {
+	case *jack_ast.BinaryExpr:
+		s.Expression(expr.X)
+		s.Expression(expr.Y)
+		s.binaryOp(expr.Op)
+	case *jack_ast.ParenExpr:
+		s.Expression(expr.X)
+	case *jack_ast.UnaryExpr:
+		if lit, ok := expr.X.(*jack_ast.IntLit); ok && expr.Op == jack_tokenizer.SYM_MINUS {
+			if i, err := strconv.Atoi(lit.Value); err == nil && i == MAX_INT+1 {
+				// -32768 has no positive counterpart in 16 bits,
+				// but its bit pattern is that of ~32767
+				s.vmWriter.WritePush(CONSTANT, MAX_INT)
+				s.vmWriter.WriteArithmetic(NOT)
+				break
 			}
-
 		}
-	case jack_tokenizer.INT_CONSTANT:
-		token, _ := s.process([]tokenpair{
-			{jack_tokenizer.INT_CONSTANT, jack_tokenizer.NONE},
-		})
-		i, _ := strconv.Atoi(token.Lexeme)
-		s.vmWriter.WritePush(CONSTANT, i)
-	case jack_tokenizer.STRING_CONSTANT:
-		token, _ := s.process([]tokenpair{
-			{jack_tokenizer.STRING_CONSTANT, jack_tokenizer.NONE},
-		})
-		s.vmWriter.WritePush(CONSTANT, len(token.Lexeme))
-		s.vmWriter.WriteCall("String.new", 1)
-		for _, c := range []byte(token.Lexeme) {
-			s.vmWriter.WritePush(CONSTANT, int(c))
-			s.vmWriter.WriteCall("String.appendChar", 1)
+		s.Expression(expr.X)
+		// output op
+		tokenName := NEG
+		if expr.Op == jack_tokenizer.SYM_TILDE {
+			tokenName = NOT
+		}
+		s.vmWriter.WriteArithmetic(tokenName)
+	case *jack_ast.Ident:
+		resolved := s.variable(expr)
+		if c, info, ok := s.program.constant(s.className, expr.Name); ok && resolved.symbol == NONE {
+			s.pushInt(s.program.constValue(info, c))
+			break
 		}
-		// push c
-	case jack_tokenizer.SYMBOL:
-		switch s.Current().Subtype {
-		case jack_tokenizer.SYM_LEFT_PAREN:
-			s.symbolHelper(jack_tokenizer.SYM_LEFT_PAREN)
-			s.Expression()
-			s.symbolHelper(jack_tokenizer.SYM_RIGHT_PAREN)
-		case jack_tokenizer.SYM_MINUS:
-		case jack_tokenizer.SYM_TILDE:
-			s.symbolHelper(s.Current().Subtype)
-			s.Term()
-			// output op
-			tokenName := SUB
-			if s.Current().Subtype == jack_tokenizer.SYM_TILDE {
-				tokenName = NOT
+		if resolved.symbol == NONE {
+			s.undefined(expr, false)
+		}
+		s.vmWriter.WritePush(fieldtoSegment[resolved.symbol], resolved.index)
+	case *jack_ast.IndexExpr:
+		// varname[expression]
+		resolved := s.variable(expr.X)
+		if resolved.symbol == NONE {
+			s.undefined(expr.X, false)
+		}
+		s.vmWriter.WritePush(fieldtoSegment[resolved.symbol], resolved.index)
+		s.Expression(expr.Index)
+		s.vmWriter.WriteArithmetic(ADD)
+		s.vmWriter.WritePop(POINTER, 1)
+		s.vmWriter.WritePush(THAT, 0)
+	case *jack_ast.CallExpr:
+		s.SubroutineCall(expr)
+	case *jack_ast.SelectorExpr:
+		s.pushInt(s.program.selector(&s.diagnostics, expr))
+	case *jack_ast.IntLit:
+		s.vmWriter.WritePush(CONSTANT, s.intConstant(expr))
+	case *jack_ast.StringLit:
+		s.vmWriter.WritePush(CONSTANT, len(expr.Value))
+		s.vmWriter.WriteCall("String.new", 1)
+		s.rawTab(expr.Pos(), expr.Raw, "string constant")
+		for _, c := range []byte(expr.Value) {
+			hc, ok := hackCharacter(c)
+			if !ok {
+				s.errorf(expr.Pos(), CodeOutsideCharset, "character %d in string constant is outside the Hack character set", c)
 			}
-			s.vmWriter.WriteArithmetic(tokenName)
+			s.vmWriter.WritePush(CONSTANT, hc)
+			s.vmWriter.WriteCall("String.appendChar", 2)
 		}
-	case jack_tokenizer.KEYWORD:
-		switch s.Current().Subtype {
-		case jack_tokenizer.KW_FALSE:
-		case jack_tokenizer.KW_NULL:
+	case *jack_ast.KeywordLit:
+		switch expr.Keyword {
+		case jack_tokenizer.KW_FALSE, jack_tokenizer.KW_NULL:
 			s.vmWriter.WritePush(CONSTANT, 0)
 		case jack_tokenizer.KW_TRUE:
 			s.vmWriter.WritePush(CONSTANT, 1)
 			s.vmWriter.WriteArithmetic(NEG)
 		case jack_tokenizer.KW_THIS:
+			s.this(expr)
 			s.vmWriter.WritePush(POINTER, 0)
 		}
-		s.keywordHelper(s.Current().Subtype)
 	}
-
 }
 
-func (s *parser) SubroutineCall() {
-	mainToken, _ := s.identifierHelper() // class's name or subroutine name, depending on if theres a .
-	mainName := mainToken.Lexeme
-	secondaryName := ""
-
-	t := s.Current()
-	s.process([]tokenpair{
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_LEFT_PAREN},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_PERIOD},
-	})
-
-	switch t.Subtype {
-	case jack_tokenizer.SYM_PERIOD:
-		fn, _ := s.identifierHelper()
-		secondaryName = "." + fn.Lexeme
-		s.symbolHelper(jack_tokenizer.SYM_LEFT_PAREN)
-
-		fallthrough
-	case jack_tokenizer.SYM_LEFT_PAREN:
-		n := s.ExpressionList()
-		s.symbolHelper(jack_tokenizer.SYM_RIGHT_PAREN)
-		s.vmWriter.WriteCall(fmt.Sprintf("%s%s", mainName, secondaryName), n)
+// pushInt pushes v, which fits in 16 bits.
+func (s *compiler) pushInt(v int) {
+	switch {
+	case v >= 0:
+		s.vmWriter.WritePush(CONSTANT, v)
+	case v == -MAX_INT-1:
+		s.vmWriter.WritePush(CONSTANT, MAX_INT)
+		s.vmWriter.WriteArithmetic(NOT)
+	default:
+		s.vmWriter.WritePush(CONSTANT, -v)
+		s.vmWriter.WriteArithmetic(NEG)
 	}
 }
 
-// Compiles a (possibly empty) comma-
-// separated list of expression. Returns
-// the number of expressions in the list
-func (s *parser) ExpressionList() int {
-	begTerm := []tokenpair{
-		{jack_tokenizer.STRING_CONSTANT, jack_tokenizer.NONE},
-		{jack_tokenizer.INT_CONSTANT, jack_tokenizer.NONE},
-		{jack_tokenizer.IDENTIFIER, jack_tokenizer.NONE},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_PLUS},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_LEFT_PAREN},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_MINUS},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_TILDE},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_TRUE},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_FALSE},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_NULL},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_THIS},
+// intConstant returns the value of an integer constant, reporting
+// values that do not fit in 15 bits. Character literals are mapped onto
+// the Hack character set like the characters of a string constant.
+func (d *diagnostics) intConstant(lit *jack_ast.IntLit) int {
+	i, err := strconv.Atoi(lit.Value)
+	if err != nil || i > MAX_INT {
+		d.errorf(lit.Pos(), CodeIntegerOutOfRange, "integer constant %s is out of range, the largest is %d", lit.Raw, MAX_INT)
+		return 0
 	}
-	count := 0
-	if s.matches(begTerm) {
-		count++
-		s.Expression()
-		for s.matches([]tokenpair{
-			{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_COMMA},
-		}) {
-			count++
-			s.symbolHelper(jack_tokenizer.SYM_COMMA)
-			s.Expression()
+
+	if strings.HasPrefix(lit.Raw, "'") {
+		d.rawTab(lit.Pos(), lit.Raw, "character literal")
+		hc, ok := hackCharacter(byte(i))
+		if !ok {
+			d.errorf(lit.Pos(), CodeOutsideCharset, "character %d in character literal is outside the Hack character set", i)
+		}
+		return hc
+	}
+
+	return i
+}
+
+// Compiles a subroutine call. An unqualified call is a method call on
+// this, and a call through a variable is a method call on the object it
+// holds; either way the object goes in as the first argument.
+func (s *compiler) SubroutineCall(call *jack_ast.CallExpr) {
+	n := len(call.Args)
+	name := fmt.Sprintf("%s.%s", s.className, call.Name.Name)
+
+	if call.Receiver == nil {
+		s.vmWriter.WritePush(POINTER, 0)
+		n++
+		dec := s.program.subroutine(&s.diagnostics, s.className, call)
+		s.callKind(call, s.className, dec, true)
+	} else if resolved := s.variable(call.Receiver); resolved.symbol != NONE {
+		s.vmWriter.WritePush(fieldtoSegment[resolved.symbol], resolved.index)
+		class := s.typeOf(resolved)
+		name = fmt.Sprintf("%s.%s", class, call.Name.Name)
+		n++
+		if _, ok := primitiveTypes[class]; !ok {
+			dec := s.program.subroutine(&s.diagnostics, class, call)
+			s.callKind(call, class, dec, true)
+		}
+	} else {
+		name = fmt.Sprintf("%s.%s", call.Receiver.Name, call.Name.Name)
+		if _, ok := s.program.classes[call.Receiver.Name]; !ok && !s.program.partial {
+			s.undefined(call.Receiver, true)
+		} else {
+			dec := s.program.subroutine(&s.diagnostics, call.Receiver.Name, call)
+			s.callKind(call, call.Receiver.Name, dec, false)
 		}
 	}
 
-	return count
+	for _, arg := range call.Args {
+		s.Expression(arg)
+	}
+	s.vmWriter.WriteCall(name, n)
 }
 
-// x* : 0 or more
-// ? one or more
-// x y x followed by y
-// x | y x or y
+// Compile writes the VM code for class to w.
+// Other classes are unknown to it, so their constants and enums cannot
+// be used and calls are not checked; compile the classes of a program
+// with a Program for those.
+func Compile(class *jack_ast.Class, w io.WriteCloser) error {
+	p := NewProgram(class)
+	p.partial = true
+
+	return p.Compile(class, w)
+}
 
 func ParseGrammar(tokens []jack_tokenizer.Token) func(io.WriteCloser) error {
+	return ParseStream(jack_tokenizer.NewSliceStream(tokens))
+}
+
+// ParseStream is like ParseGrammar, but pulls tokens from ts as the
+// grammar asks for them instead of needing the whole file up front.
+func ParseStream(ts jack_tokenizer.TokenStream, opts ...jack_parser.Option) func(io.WriteCloser) error {
 	return func(w io.WriteCloser) error {
-		parser := NewParser(tokens, *NewVMWriter(w))
+		class, err := jack_parser.ParseFile(ts, opts...)
+		if err != nil {
+			return err
+		}
 
-		err := parser.Parse()
-		return err
+		return Compile(class, w)
 	}
 }
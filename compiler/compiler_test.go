@@ -0,0 +1,606 @@
+package jack_compiler
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	jack_ast "github.com/renojcpp/n2t-compiler/ast"
+	jack_parser "github.com/renojcpp/n2t-compiler/parser"
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+)
+
+type nopCloser struct {
+	strings.Builder
+}
+
+func (nopCloser) Close() error { return nil }
+
+// compile runs the compiler over src and returns the VM code.
+func compile(t *testing.T, src string, opts ...jack_tokenizer.Option) (string, error) {
+	t.Helper()
+
+	opts = append(opts, jack_tokenizer.WithFilename("Main.jack"))
+	var out nopCloser
+	err := ParseStream(jack_tokenizer.NewLexer(strings.NewReader(src), opts...))(&out)
+
+	return out.String(), err
+}
+
+func TestStringConstants(t *testing.T) {
+	tests := []struct {
+		name string
+		lit  string
+		want []int
+		err  jack_tokenizer.Code
+	}{
+		{"plain", `"Hi"`, []int{'H', 'i'}, ""},
+		{"newline", `"a\n"`, []int{'a', HACK_NEWLINE}, ""},
+		{"tab", `"\t"`, []int{' '}, ""},
+		{"raw tab", "\"a\tb\"", []int{'a', ' ', 'b'}, CodeOutsideCharset},
+		{"hack code", `"\u{131}"`, []int{131}, ""},
+		{"outside", `"\u{127}"`, []int{0}, CodeOutsideCharset},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "class Main { function void main() { do Output.printString(" + tt.lit + "); return; } }"
+			vm, err := compile(t, src, jack_tokenizer.WithEscapes())
+
+			if tt.err == "" && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if tt.err != "" {
+				if diag, ok := err.(jack_tokenizer.Diagnostic); !ok || diag.Code != tt.err {
+					t.Fatalf("got error %v, wanted %s", err, tt.err)
+				}
+			}
+
+			var want strings.Builder
+			for _, c := range tt.want {
+				want.WriteString("push constant " + strconv.Itoa(c) + "\ncall String.appendChar 2\n")
+			}
+			if !strings.Contains(vm, want.String()) {
+				t.Errorf("got\n%s\nwanted it to contain\n%s", vm, want.String())
+			}
+		})
+	}
+}
+
+func TestIntegerConstants(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want string
+		err  jack_tokenizer.Code
+	}{
+		{"decimal", "32767", "push constant 32767\n", ""},
+		{"hex", "0x7FFF", "push constant 32767\n", ""},
+		{"binary", "0b1010_0101", "push constant 165\n", ""},
+		{"character", "'A'", "push constant 65\n", ""},
+		{"raw tab character", "'\t'", "", CodeOutsideCharset},
+		{"negated", "-5", "push constant 5\nneg\n", ""},
+		{"most negative", "-32768", "push constant 32767\nnot\n", ""},
+		{"most negative hex", "-0x8000", "push constant 32767\nnot\n", ""},
+		{"too large", "40000", "", CodeIntegerOutOfRange},
+		{"too large unnegated", "32768", "", CodeIntegerOutOfRange},
+		{"too large negated", "-32769", "", CodeIntegerOutOfRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "class Main { function int main() { return " + tt.expr + "; } }"
+			vm, err := compile(t, src, jack_tokenizer.WithExtendedLiterals())
+
+			if tt.err != "" {
+				if diag, ok := err.(jack_tokenizer.Diagnostic); !ok || diag.Code != tt.err {
+					t.Fatalf("got error %v, wanted %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if want := "function Main.main 0\n" + tt.want + "return\n"; vm != want {
+				t.Errorf("got\n%s\nwanted\n%s", vm, want)
+			}
+		})
+	}
+}
+
+func TestCompileClass(t *testing.T) {
+	src := `class Point {
+  field int x, y;
+  static Array cache;
+
+  constructor Point new(int ax) {
+    let x = ax;
+    return this;
+  }
+
+  method int step(Point other) {
+    var int i;
+    if (x & false) { let cache[i] = other.get(); } else { do move(); }
+    while (i < 2) { let i = i + 1; }
+    return y;
+  }
+}`
+	want := `function Point.new 0
+push constant 2
+call Memory.alloc 1
+pop pointer 0
+push argument 0
+pop this 0
+push pointer 0
+return
+function Point.step 1
+push argument 0
+pop pointer 0
+push this 0
+push constant 0
+and
+not
+if-goto Point.IF-0
+push static 0
+push local 0
+add
+push argument 1
+call Point.get 1
+pop temp 0
+pop pointer 1
+push temp 0
+pop that 0
+goto Point.IF-1
+label Point.IF-0
+push pointer 0
+call Point.move 1
+pop temp 0
+label Point.IF-1
+label Point.WHILE-2
+push local 0
+push constant 2
+lt
+not
+if-goto Point.WHILE-3
+push local 0
+push constant 1
+add
+pop local 0
+goto Point.WHILE-2
+label Point.WHILE-3
+push this 1
+return
+`
+	vm, err := compile(t, src)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if vm != want {
+		t.Errorf("got\n%s\nwanted\n%s", vm, want)
+	}
+}
+
+func TestCompileExtendedStatements(t *testing.T) {
+	src := `class Main {
+  function void f(int n) {
+    var int i;
+    for (let i = 0; i < n; let i = i + 1) {
+      if (i = 2) { continue; } else if (i = 5) { break; }
+      while (n) { break; }
+    }
+    return;
+  }
+}`
+	want := `function Main.f 1
+push constant 0
+pop local 0
+label Main.FOR-0
+push local 0
+push argument 0
+lt
+not
+if-goto Main.FOR-2
+push local 0
+push constant 2
+eq
+not
+if-goto Main.IF-3
+goto Main.FOR-1
+goto Main.IF-4
+label Main.IF-3
+push local 0
+push constant 5
+eq
+not
+if-goto Main.IF-5
+goto Main.FOR-2
+goto Main.IF-6
+label Main.IF-5
+label Main.IF-6
+label Main.IF-4
+label Main.WHILE-7
+push argument 0
+not
+if-goto Main.WHILE-8
+goto Main.WHILE-8
+goto Main.WHILE-7
+label Main.WHILE-8
+label Main.FOR-1
+push local 0
+push constant 1
+add
+pop local 0
+goto Main.FOR-0
+label Main.FOR-2
+push constant 0
+return
+`
+	lexer := jack_tokenizer.NewLexer(strings.NewReader(src), jack_tokenizer.WithExtendedStatements())
+	var out nopCloser
+	if err := ParseStream(lexer, jack_parser.WithExtendedStatements())(&out); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if vm := out.String(); vm != want {
+		t.Errorf("got\n%s\nwanted\n%s", vm, want)
+	}
+}
+
+func TestCompileCompoundAssignment(t *testing.T) {
+	src := `class Main {
+  function void f(Array a, int x) {
+    let x *= x + 1;
+    let a[Main.g()] -= a[0];
+    return;
+  }
+}`
+	want := `function Main.f 0
+push argument 1
+push argument 1
+push constant 1
+add
+call Math.multiply 2
+pop argument 1
+push argument 0
+call Main.g 0
+add
+pop temp 0
+push temp 0
+push temp 0
+pop pointer 1
+push that 0
+push argument 0
+push constant 0
+add
+pop pointer 1
+push that 0
+sub
+pop temp 0
+pop pointer 1
+push temp 0
+pop that 0
+push constant 0
+return
+`
+	lexer := jack_tokenizer.NewLexer(strings.NewReader(src), jack_tokenizer.WithCompoundAssignment())
+	var out nopCloser
+	if err := ParseStream(lexer, jack_parser.WithCompoundAssignment())(&out); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if vm := out.String(); vm != want {
+		t.Errorf("got\n%s\nwanted\n%s", vm, want)
+	}
+}
+
+// parseConstants parses src with the constants dialect.
+func parseConstants(t *testing.T, name, src string) *jack_ast.Class {
+	t.Helper()
+
+	lexer := jack_tokenizer.NewLexer(strings.NewReader(src), jack_tokenizer.WithConstants(), jack_tokenizer.WithFilename(name))
+	class, err := jack_parser.ParseFile(lexer, jack_parser.WithConstants())
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+
+	return class
+}
+
+func TestCompileConstants(t *testing.T) {
+	game := parseConstants(t, "Game.jack", `class Game {
+  const int MAX = 512;
+  const int MIN = -Main.LOW - MAX;
+  enum Dir { UP, DOWN, LEFT, RIGHT }
+}`)
+	main := parseConstants(t, "Main.jack", `class Main {
+  const int LOW = 65 * 2;
+  const boolean ON = LOW > 100;
+  function void f(int MAX) {
+    let MAX = Game.MAX + Dir.RIGHT;
+    let MAX = Game.MIN;
+    let MAX = ON;
+    return;
+  }
+}`)
+	want := `function Main.f 0
+push constant 512
+push constant 3
+add
+pop argument 0
+push constant 642
+neg
+pop argument 0
+push constant 1
+neg
+pop argument 0
+push constant 0
+return
+`
+
+	program := NewProgram(game, main)
+	var out nopCloser
+	if err := program.Compile(main, &out); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if vm := out.String(); vm != want {
+		t.Errorf("got\n%s\nwanted\n%s", vm, want)
+	}
+}
+
+func TestConstantErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want jack_tokenizer.Code
+	}{
+		{"assign", "const int MAX = 1; function void f() { let MAX = 2; return; }", CodeAssignToConstant},
+		{"assign itself", "const int MAX = 1; function void f() { let MAX = MAX + 1; return; }", CodeAssignToConstant},
+		{"unknown class", "function int f() { return Game.MAX; }", CodeUnknownConstant},
+		{"unknown member", "enum Dir { UP } function int f() { return Dir.DOWN; }", CodeUnknownConstant},
+		{"not constant", "const int A = Math.abs(1);", CodeNotConstant},
+		{"unknown name", "const int A = B;", CodeUnknownConstant},
+		{"cycle", "const int A = B; const int B = A + 1;", CodeConstantCycle},
+		{"type", "const String S = 1;", CodeConstantType},
+		{"redeclared", "field int A; const int A = 1;", CodeRedeclared},
+		{"enum member", "enum Dir { UP, UP }", CodeRedeclared},
+		{"enum named as class", "enum Main { A }", CodeRedeclared},
+		{"division by zero", "const int A = 1 / (2 - 2);", CodeDivisionByZero},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			class := parseConstants(t, "Main.jack", "class Main { "+tt.src+" }")
+			var out nopCloser
+			err := Compile(class, &out)
+			if diag, ok := err.(jack_tokenizer.Diagnostic); !ok || diag.Code != tt.want {
+				t.Errorf("got error %v, wanted %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallChecks(t *testing.T) {
+	game, err := jack_parser.ParseFile(jack_tokenizer.NewLexer(strings.NewReader(`class Game {
+  constructor Game new() { return this; }
+  method void step(int n) { return; }
+  function int score() { return 0; }
+}`)))
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+
+	tests := []struct {
+		name string
+		stmt string
+		want jack_tokenizer.Code
+	}{
+		{"function", "do Game.score();", ""},
+		{"method", "do g.step(1);", ""},
+		{"unqualified", "do f(g);", ""},
+		{"os", "do Output.printInt(Math.max(1, 2));", ""},
+		{"unknown class", "do Foo.bar(1, 2);", CodeUnknownClass},
+		{"unknown subroutine", "do Game.bar();", CodeUnknownSubroutine},
+		{"unknown method", "do g.bar();", CodeUnknownSubroutine},
+		{"unknown unqualified", "do bar();", CodeUnknownSubroutine},
+		{"too many", "do Game.score(1);", CodeArgumentCount},
+		{"too few", "do g.step();", CodeArgumentCount},
+		{"os arity", "do Output.printInt(1, 2);", CodeArgumentCount},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "class Main { method void f(Game g) { " + tt.stmt + " return; } }"
+			main, err := jack_parser.ParseFile(jack_tokenizer.NewLexer(strings.NewReader(src)))
+			if err != nil {
+				t.Fatalf("failed to parse: %s", err)
+			}
+
+			var out nopCloser
+			err = NewProgram(game, main).Compile(main, &out)
+			if tt.want == "" && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if tt.want != "" {
+				if diag, ok := err.(jack_tokenizer.Diagnostic); !ok || diag.Code != tt.want {
+					t.Errorf("got error %v, wanted %s", err, tt.want)
+				}
+			}
+
+			// compiled on its own, Main knows nothing of Game
+			if err := Compile(main, &nopCloser{}); err != nil {
+				t.Errorf("on its own: unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestUndefined(t *testing.T) {
+	tests := []struct {
+		name string
+		stmt string
+		want string
+	}{
+		{"value", "let count = cuont + 1;", "1:102: undefined variable cuont, did you mean count?"},
+		{"target", "let cont = 1;", "1:94: undefined variable cont, did you mean count?"},
+		{"array", "let count = items[0];", "1:102: undefined variable items"},
+		{"field", "let count = sizee;", "1:102: undefined variable sizee, did you mean size?"},
+		{"argument", "let count = stpe;", "1:102: undefined variable stpe, did you mean step?"},
+		{"constant", "let count = MAXX;", "1:102: undefined variable MAXX, did you mean MAX?"},
+		{"class", "do Outptu.printInt(count);", "1:93: undefined variable or class Outptu, did you mean Output?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "class Main { field int size; const int MAX = 1; method void f(int step) { var int count; " + tt.stmt + " return; } }"
+			main := parseConstants(t, "", src)
+
+			err := NewProgram(main).Compile(main, &nopCloser{})
+			diag, ok := err.(jack_tokenizer.Diagnostic)
+			if got := fmt.Sprintf("%s: %s", diag.Pos, diag.Msg); !ok || got != tt.want {
+				t.Errorf("got %v, wanted %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedeclared(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{"local", "class Main { function void f() { var int x, x; let y = 1; return; } }", []string{"1:45: x redeclared", "1:52: undefined variable y, did you mean x?"}},
+		{"argument", "class Main { function void f(int x) { var char x; return; } }", []string{"1:48: x redeclared"}},
+		{"field", "class Main { field int x; static int x; }", []string{"1:38: x redeclared"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			main := parseConstants(t, "", tt.src)
+
+			var got []string
+			switch err := NewProgram(main).Compile(main, &nopCloser{}).(type) {
+			case jack_tokenizer.Diagnostic:
+				got = append(got, fmt.Sprintf("%s: %s", err.Pos, err.Msg))
+			case jack_tokenizer.Diagnostics:
+				for _, diag := range err {
+					got = append(got, fmt.Sprintf("%s: %s", diag.Pos, diag.Msg))
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, wanted %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReturns(t *testing.T) {
+	tests := []struct {
+		name string
+		dec  string
+		want jack_tokenizer.Code
+	}{
+		{"return", "function int f() { return 1; }", ""},
+		{"if else", "function int f(int n) { if (n) { return 1; } else { return 2; } }", ""},
+		{"else if", "function int f(int n) { if (n) { return 1; } else if (~n) { return 2; } else { return 3; } }", ""},
+		{"forever", "function int f() { while (true) { } }", ""},
+		{"for ever", "function int f() { for (;;) { } }", ""},
+		{"void", "function void f() { }", ""},
+		{"constructor", "constructor Main new() { return this; }", ""},
+		{"missing", "function int f() { }", CodeMissingReturn},
+		{"if only", "function int f(int n) { if (n) { return 1; } }", CodeMissingReturn},
+		{"while", "function int f(int n) { while (n) { return 1; } }", CodeMissingReturn},
+		{"break", "function int f() { while (true) { if (false) { break; } } }", CodeMissingReturn},
+		{"constructor missing", "constructor Main new() { }", CodeMissingReturn},
+		{"void value", "function void f() { return 1; }", CodeReturnValue},
+		{"no value", "function int f() { return; }", CodeReturnValue},
+		{"constructor other", "constructor Main new() { return null; }", CodeConstructorReturn},
+		{"constructor bare", "constructor Main new() { return; }", CodeConstructorReturn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lexer := jack_tokenizer.NewLexer(strings.NewReader("class Main { "+tt.dec+" }"), jack_tokenizer.WithExtendedStatements())
+			var out nopCloser
+			err := ParseStream(lexer, jack_parser.WithExtendedStatements())(&out)
+			if tt.want == "" && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if tt.want != "" {
+				if diag, ok := err.(jack_tokenizer.Diagnostic); !ok || diag.Code != tt.want {
+					t.Errorf("got error %v, wanted %s", err, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestVoidReturn(t *testing.T) {
+	src := "class Main { function void f(int n) { if (n) { return; } } }"
+	want := `function Main.f 0
+push argument 0
+not
+if-goto Main.IF-0
+push constant 0
+return
+goto Main.IF-1
+label Main.IF-0
+label Main.IF-1
+push constant 0
+return
+`
+	vm, err := compile(t, src)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if vm != want {
+		t.Errorf("got\n%s\nwanted\n%s", vm, want)
+	}
+}
+
+func TestSubroutineKinds(t *testing.T) {
+	game, err := jack_parser.ParseFile(jack_tokenizer.NewLexer(strings.NewReader(`class Game {
+  constructor Game new() { return this; }
+  method void step() { return; }
+  function int score() { return 0; }
+}`)))
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+
+	tests := []struct {
+		name string
+		dec  string
+		want string
+	}{
+		{"method", "field int x; method void f() { do g(); let x = this; return; } method void g() { return; }", ""},
+		{"constructor", "field int x; constructor Main new() { let x = 1; do g(); return this; } method void g() { return; }", ""},
+		{"function", "function void f() { do Main.g(); do Game.new(); return; } function void g() { return; }", ""},
+		{"this in function", "function Main f() { return this; }",
+			"1:41: this cannot be used in function Main.f, which has no object; only methods and constructors have one"},
+		{"field in function", "field int x; function int f() { return x; }",
+			"1:53: field x cannot be used in function Main.f, which has no object; only methods and constructors can use fields"},
+		{"field assigned in function", "field int x; function void f() { let x = 1; return; }",
+			"1:51: field x cannot be used in function Main.f, which has no object; only methods and constructors can use fields"},
+		{"method from function", "function void f() { do g(); return; } method void g() { return; }",
+			"1:37: method g cannot be called without an object in function Main.f, which has no this to call it on"},
+		{"function on this", "method void f() { do g(); return; } function void g() { return; }",
+			"1:35: function Main.g cannot be called on an object, only methods can; call it as Main.g"},
+		{"constructor on object", "function void f(Game g) { do g.new(); return; }",
+			"1:45: constructor Game.new cannot be called on an object, only methods can; call it as Game.new"},
+		{"method through class", "function void f() { do Game.step(); return; }",
+			"1:42: method Game.step must be called on an object, not through its class"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			main, err := jack_parser.ParseFile(jack_tokenizer.NewLexer(strings.NewReader("class Main { " + tt.dec + " }")))
+			if err != nil {
+				t.Fatalf("failed to parse: %s", err)
+			}
+
+			err = NewProgram(game, main).Compile(main, &nopCloser{})
+			if tt.want == "" && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if tt.want != "" {
+				diag, ok := err.(jack_tokenizer.Diagnostic)
+				if got := fmt.Sprintf("%s: %s", diag.Pos, diag.Msg); !ok || got != tt.want {
+					t.Errorf("got %v, wanted %s", err, tt.want)
+				}
+			}
+		})
+	}
+}
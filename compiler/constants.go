@@ -0,0 +1,154 @@
+package jack_compiler
+
+import (
+	jack_ast "github.com/renojcpp/n2t-compiler/ast"
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+)
+
+const (
+	CodeAssignToConstant jack_tokenizer.Code = "assign-to-constant"
+	CodeUnknownConstant  jack_tokenizer.Code = "unknown-constant"
+	CodeNotConstant      jack_tokenizer.Code = "not-constant"
+	CodeRedeclared       jack_tokenizer.Code = "redeclared"
+	CodeConstantCycle    jack_tokenizer.Code = "constant-cycle"
+	CodeConstantType     jack_tokenizer.Code = "constant-type"
+	CodeDivisionByZero   jack_tokenizer.Code = "division-by-zero"
+)
+
+type constState int
+
+const (
+	unresolved constState = iota
+	resolving
+	resolved
+)
+
+// constant is a class constant, whose value is worked out the first
+// time it is asked for.
+type constant struct {
+	dec   *jack_ast.ConstDec
+	state constState
+	value int
+}
+
+// constValue returns the value of c, a constant of the class info.
+func (p *Program) constValue(info *classInfo, c *constant) int {
+	switch c.state {
+	case resolved:
+		return c.value
+	case resolving:
+		info.errorf(c.dec.Name.Pos(), CodeConstantCycle, "constant %s is defined in terms of itself", c.dec.Name.Name)
+		return 0
+	}
+
+	c.state = resolving
+	switch c.dec.Type.Keyword {
+	case jack_tokenizer.KW_INT, jack_tokenizer.KW_CHAR, jack_tokenizer.KW_BOOLEAN:
+	default:
+		info.errorf(c.dec.Type.NamePos, CodeConstantType, "constant %s must be an int, char or boolean", c.dec.Name.Name)
+	}
+	c.value = p.eval(&info.diagnostics, info.class.Name.Name, c.dec.Value)
+	c.state = resolved
+
+	return c.value
+}
+
+// eval works out the value of a constant expression in class. Values
+// wrap around to 16 bits as they would in the VM, and comparisons give
+// -1 for true and 0 for false.
+func (p *Program) eval(d *diagnostics, class string, expr jack_ast.Expr) int {
+	switch expr := expr.(type) {
+	case *jack_ast.IntLit:
+		return d.intConstant(expr)
+	case *jack_ast.KeywordLit:
+		switch expr.Keyword {
+		case jack_tokenizer.KW_TRUE:
+			return -1
+		case jack_tokenizer.KW_FALSE, jack_tokenizer.KW_NULL:
+			return 0
+		}
+	case *jack_ast.ParenExpr:
+		return p.eval(d, class, expr.X)
+	case *jack_ast.UnaryExpr:
+		if lit, ok := expr.X.(*jack_ast.IntLit); ok && expr.Op == jack_tokenizer.SYM_MINUS && lit.Value == "32768" {
+			return -MAX_INT - 1
+		}
+		x := p.eval(d, class, expr.X)
+		if expr.Op == jack_tokenizer.SYM_TILDE {
+			return wrap(^x)
+		}
+		return wrap(-x)
+	case *jack_ast.BinaryExpr:
+		x, y := p.eval(d, class, expr.X), p.eval(d, class, expr.Y)
+		switch expr.Op {
+		case jack_tokenizer.SYM_PLUS:
+			return wrap(x + y)
+		case jack_tokenizer.SYM_MINUS:
+			return wrap(x - y)
+		case jack_tokenizer.SYM_ASTERISK:
+			return wrap(x * y)
+		case jack_tokenizer.SYM_SLASH:
+			if y == 0 {
+				d.errorf(expr.OpPos, CodeDivisionByZero, "division by zero")
+				return 0
+			}
+			return wrap(x / y)
+		case jack_tokenizer.SYM_AMPERSAND:
+			return x & y
+		case jack_tokenizer.SYM_PIPE:
+			return x | y
+		case jack_tokenizer.SYM_LESS_THAN:
+			return truth(x < y)
+		case jack_tokenizer.SYM_GREATER_THAN:
+			return truth(x > y)
+		case jack_tokenizer.SYM_EQUALS:
+			return truth(x == y)
+		}
+	case *jack_ast.Ident:
+		if c, info, ok := p.constant(class, expr.Name); ok {
+			return p.constValue(info, c)
+		}
+		d.errorf(expr.Pos(), CodeUnknownConstant, "%s is not a constant of class %s", expr.Name, class)
+		return 0
+	case *jack_ast.SelectorExpr:
+		return p.selector(d, expr)
+	case *jack_ast.BadExpr:
+		return 0
+	}
+
+	d.errorf(expr.Pos(), CodeNotConstant, "expression is not constant")
+	return 0
+}
+
+// selector returns the value of Class.NAME or Enum.MEMBER.
+func (p *Program) selector(d *diagnostics, sel *jack_ast.SelectorExpr) int {
+	if enum, ok := p.enums[sel.X.Name]; ok {
+		if i, ok := enum.members[sel.Sel.Name]; ok {
+			return i
+		}
+		d.errorf(sel.Sel.Pos(), CodeUnknownConstant, "enum %s has no member %s", sel.X.Name, sel.Sel.Name)
+		return 0
+	}
+
+	if _, ok := p.classes[sel.X.Name]; !ok {
+		d.errorf(sel.X.Pos(), CodeUnknownConstant, "%s is not a class or enum", sel.X.Name)
+		return 0
+	}
+	if c, info, ok := p.constant(sel.X.Name, sel.Sel.Name); ok {
+		return p.constValue(info, c)
+	}
+	d.errorf(sel.Sel.Pos(), CodeUnknownConstant, "class %s has no constant %s", sel.X.Name, sel.Sel.Name)
+	return 0
+}
+
+// wrap truncates v to 16 bits.
+func wrap(v int) int {
+	return int(int16(v))
+}
+
+func truth(b bool) int {
+	if b {
+		return -1
+	}
+	return 0
+}
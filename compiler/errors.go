@@ -0,0 +1,33 @@
+package jack_compiler
+
+import (
+	"sort"
+
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+)
+
+// diagnostics collects the problems found in a class.
+type diagnostics struct {
+	diags jack_tokenizer.Diagnostics
+}
+
+// errorf records an error.
+func (d *diagnostics) errorf(pos jack_tokenizer.Position, code jack_tokenizer.Code, format string, args ...interface{}) {
+	d.diags = append(d.diags, jack_tokenizer.NewDiagnostic(jack_tokenizer.SeverityError, code, pos, format, args...))
+}
+
+// err returns nil if no errors were found, the Diagnostic if there was
+// one, and otherwise all of them in source order.
+func (d *diagnostics) err() error {
+	switch {
+	case !d.diags.HasErrors():
+		return nil
+	case len(d.diags) == 1:
+		return d.diags[0]
+	}
+
+	sort.SliceStable(d.diags, func(i, j int) bool {
+		return d.diags[i].Pos.Offset < d.diags[j].Pos.Offset
+	})
+	return d.diags
+}
@@ -0,0 +1,61 @@
+package jack_compiler
+
+import (
+	"strings"
+	"testing"
+
+	jack_parser "github.com/renojcpp/n2t-compiler/parser"
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+)
+
+// fuzzOptions switches on a language extension for each bit set in
+// flags, in the tokenizer and the parser both, so that the fuzzer
+// explores the code generated for every dialect.
+func fuzzOptions(flags uint8) ([]jack_tokenizer.Option, []jack_parser.Option) {
+	var lexOpts []jack_tokenizer.Option
+	var opts []jack_parser.Option
+	if flags&1 != 0 {
+		lexOpts = append(lexOpts, jack_tokenizer.WithExtendedStatements())
+		opts = append(opts, jack_parser.WithExtendedStatements())
+	}
+	if flags&2 != 0 {
+		lexOpts = append(lexOpts, jack_tokenizer.WithCompoundAssignment())
+		opts = append(opts, jack_parser.WithCompoundAssignment())
+	}
+	if flags&4 != 0 {
+		lexOpts = append(lexOpts, jack_tokenizer.WithConstants())
+		opts = append(opts, jack_parser.WithConstants())
+	}
+	if flags&8 != 0 {
+		lexOpts = append(lexOpts, jack_tokenizer.WithEscapes(), jack_tokenizer.WithExtendedLiterals())
+	}
+	if flags&16 != 0 {
+		opts = append(opts, jack_parser.WithPrecedence())
+	}
+
+	return lexOpts, opts
+}
+
+// FuzzParseGrammar checks that compiling never panics, and that the same
+// tokens rendered back to source compile to the same VM code.
+func FuzzParseGrammar(f *testing.F) {
+	f.Fuzz(func(t *testing.T, src string, flags uint8) {
+		lexOpts, opts := fuzzOptions(flags)
+		tokens, err := jack_tokenizer.Tokenize(strings.NewReader(src), lexOpts...)
+
+		var out nopCloser
+		ParseStream(jack_tokenizer.NewSliceStream(tokens), opts...)(&out)
+		if err != nil {
+			// tokens with lexical errors need not render back the same
+			return
+		}
+
+		again, _ := jack_tokenizer.Tokenize(strings.NewReader(jack_tokenizer.Render(tokens)), lexOpts...)
+		var rendered nopCloser
+		ParseStream(jack_tokenizer.NewSliceStream(again), opts...)(&rendered)
+
+		if out.String() != rendered.String() {
+			t.Fatalf("rendered source compiles differently:\n%s\n%s", out.String(), rendered.String())
+		}
+	})
+}
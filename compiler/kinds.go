@@ -0,0 +1,58 @@
+package jack_compiler
+
+import (
+	jack_ast "github.com/renojcpp/n2t-compiler/ast"
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+)
+
+const (
+	CodeNoObject  jack_tokenizer.Code = "no-object"
+	CodeNotMethod jack_tokenizer.Code = "not-method"
+)
+
+// inFunction reports whether the subroutine being compiled is a
+// function, which has no this.
+func (s *compiler) inFunction() bool {
+	return s.subroutine.Keyword == jack_tokenizer.KW_FUNCTION
+}
+
+// variable resolves a name used in the subroutine being compiled,
+// reporting a field used in a function.
+func (s *compiler) variable(name *jack_ast.Ident) symboldata {
+	resolved := s.resolveSymbol(name.Name)
+	if resolved.symbol == FIELD && s.inFunction() {
+		s.errorf(name.Pos(), CodeNoObject, "field %s cannot be used in function %s.%s, which has no object; only methods and constructors can use fields",
+			name.Name, s.className, s.subroutine.Name.Name)
+	}
+
+	return resolved
+}
+
+// this reports this used in a function.
+func (s *compiler) this(lit *jack_ast.KeywordLit) {
+	if s.inFunction() {
+		s.errorf(lit.Pos(), CodeNoObject, "this cannot be used in function %s.%s, which has no object; only methods and constructors have one",
+			s.className, s.subroutine.Name.Name)
+	}
+}
+
+// callKind checks that a call goes to the kind of subroutine it is
+// written for: one on an object, unqualified or through a variable, to a
+// method, and one through a class name to a function or constructor.
+// dec is the subroutine called, or nil if it is not known; a program
+// that knows every class has reported that already.
+func (s *compiler) callKind(call *jack_ast.CallExpr, class string, dec *jack_ast.SubroutineDec, object bool) {
+	isMethod := dec == nil || dec.Keyword == jack_tokenizer.KW_METHOD
+
+	switch {
+	case object && !isMethod:
+		s.errorf(call.Name.Pos(), CodeNotMethod, "%s %s.%s cannot be called on an object, only methods can; call it as %s.%s",
+			dec.Keyword.Spelling(), class, dec.Name.Name, class, dec.Name.Name)
+	case object && call.Receiver == nil && s.inFunction() && (dec != nil || s.program.partial):
+		s.errorf(call.Name.Pos(), CodeNoObject, "method %s cannot be called without an object in function %s.%s, which has no this to call it on",
+			call.Name.Name, s.className, s.subroutine.Name.Name)
+	case !object && dec != nil && isMethod:
+		s.errorf(call.Name.Pos(), CodeNoObject, "method %s.%s must be called on an object, not through its class",
+			class, dec.Name.Name)
+	}
+}
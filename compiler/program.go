@@ -0,0 +1,183 @@
+package jack_compiler
+
+import (
+	"io"
+
+	jack_ast "github.com/renojcpp/n2t-compiler/ast"
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+	jack_types "github.com/renojcpp/n2t-compiler/types"
+)
+
+const (
+	CodeUnknownClass      jack_tokenizer.Code = "unknown-class"
+	CodeUnknownSubroutine jack_tokenizer.Code = "unknown-subroutine"
+	CodeArgumentCount     jack_tokenizer.Code = "argument-count"
+)
+
+// primitiveTypes are the types whose values are not objects.
+var primitiveTypes = map[string]struct{}{"int": {}, "char": {}, "boolean": {}}
+
+// Program holds what is known about every class compiled together, so
+// that one class can use the constants and enums of another and calls
+// between classes can be checked. The classes of the Jack OS are part
+// of every program.
+type Program struct {
+	classes map[string]*classInfo
+	enums   map[string]*enumInfo
+	partial bool // not every class of the program is known, so calls are not checked against them
+}
+
+// classInfo is what a Program knows about one class. Problems with its
+// declarations are kept until the class is compiled.
+type classInfo struct {
+	diagnostics
+	class       *jack_ast.Class
+	consts      map[string]*constant
+	subroutines map[string]*jack_ast.SubroutineDec
+}
+
+type enumInfo struct {
+	dec     *jack_ast.EnumDec
+	members map[string]int
+}
+
+// NewProgram indexes classes and works out the value of every constant
+// they declare.
+func NewProgram(classes ...*jack_ast.Class) *Program {
+	p := &Program{
+		make(map[string]*classInfo, len(classes)),
+		make(map[string]*enumInfo),
+		false,
+	}
+
+	// a class of the program may stand in for one of the OS
+	var infos []*classInfo
+	for _, list := range [][]*jack_ast.Class{classes, jack_types.OS} {
+		for _, class := range list {
+			if _, ok := p.classes[class.Name.Name]; ok {
+				continue
+			}
+			info := &classInfo{
+				class:       class,
+				consts:      make(map[string]*constant),
+				subroutines: make(map[string]*jack_ast.SubroutineDec, len(class.Subroutines)),
+			}
+			p.classes[class.Name.Name] = info
+			infos = append(infos, info)
+		}
+	}
+
+	for _, info := range infos {
+		p.declare(info)
+	}
+	for _, info := range infos {
+		for _, dec := range info.class.Consts {
+			if c, ok := info.consts[dec.Name.Name]; ok && c.dec == dec {
+				p.constValue(info, c)
+			}
+		}
+	}
+
+	return p
+}
+
+// declare records the constants, enums and subroutines of a class.
+func (p *Program) declare(info *classInfo) {
+	for _, dec := range info.class.Subroutines {
+		name := dec.Name
+		if _, ok := info.subroutines[name.Name]; ok {
+			info.errorf(name.Pos(), CodeRedeclared, "subroutine %s redeclared in class %s", name.Name, info.class.Name.Name)
+			continue
+		}
+		info.subroutines[name.Name] = dec
+	}
+
+	vars := make(map[string]bool)
+	for _, dec := range info.class.Decls() {
+		switch dec := dec.(type) {
+		case *jack_ast.ClassVarDec:
+			for _, name := range dec.Names {
+				if _, ok := info.consts[name.Name]; ok {
+					info.errorf(name.Pos(), CodeRedeclared, "%s redeclared in class %s", name.Name, info.class.Name.Name)
+				}
+				vars[name.Name] = true
+			}
+		case *jack_ast.ConstDec:
+			name := dec.Name
+			if _, ok := info.consts[name.Name]; ok || vars[name.Name] {
+				info.errorf(name.Pos(), CodeRedeclared, "%s redeclared in class %s", name.Name, info.class.Name.Name)
+				continue
+			}
+			info.consts[name.Name] = &constant{dec: dec}
+		case *jack_ast.EnumDec:
+			name := dec.Name
+			if _, ok := p.classes[name.Name]; ok {
+				info.errorf(name.Pos(), CodeRedeclared, "enum %s has the name of a class", name.Name)
+				continue
+			}
+			if _, ok := p.enums[name.Name]; ok {
+				info.errorf(name.Pos(), CodeRedeclared, "enum %s redeclared", name.Name)
+				continue
+			}
+
+			enum := &enumInfo{dec, make(map[string]int, len(dec.Members))}
+			for _, member := range dec.Members {
+				if _, ok := enum.members[member.Name]; ok {
+					info.errorf(member.Pos(), CodeRedeclared, "%s redeclared in enum %s", member.Name, name.Name)
+					continue
+				}
+				enum.members[member.Name] = len(enum.members)
+			}
+			p.enums[name.Name] = enum
+		}
+	}
+}
+
+// constant returns the constant name of class.
+func (p *Program) constant(class, name string) (*constant, *classInfo, bool) {
+	info, ok := p.classes[class]
+	if !ok {
+		return nil, nil, false
+	}
+	c, ok := info.consts[name]
+
+	return c, info, ok
+}
+
+// subroutine returns the declaration of the subroutine a call names,
+// reporting to d when there is no such subroutine or the call passes it
+// the wrong number of arguments. It returns nil if the subroutine is not
+// known. A partial program reports nothing.
+func (p *Program) subroutine(d *diagnostics, class string, call *jack_ast.CallExpr) *jack_ast.SubroutineDec {
+	info, ok := p.classes[class]
+	if !ok {
+		if !p.partial {
+			d.errorf(call.Receiver.Pos(), CodeUnknownClass, "unknown class %s", class)
+		}
+		return nil
+	}
+	dec, ok := info.subroutines[call.Name.Name]
+	if !ok {
+		if !p.partial {
+			d.errorf(call.Name.Pos(), CodeUnknownSubroutine, "class %s has no subroutine %s", class, call.Name.Name)
+		}
+		return nil
+	}
+	if len(call.Args) != len(dec.Params) && !p.partial {
+		d.errorf(call.Name.Pos(), CodeArgumentCount, "wrong number of arguments to %s.%s: got %d, wanted %d",
+			class, call.Name.Name, len(call.Args), len(dec.Params))
+	}
+
+	return dec
+}
+
+// Compile writes the VM code for class, one of the classes of p, to w.
+func (p *Program) Compile(class *jack_ast.Class, w io.WriteCloser) error {
+	s := newCompiler(p, *NewVMWriter(w))
+	if info, ok := p.classes[class.Name.Name]; ok && info.class == class {
+		s.diags = append(s.diags, info.diags...)
+	}
+	s.Class(class)
+
+	return s.err()
+}
@@ -0,0 +1,75 @@
+package jack_compiler
+
+import (
+	jack_ast "github.com/renojcpp/n2t-compiler/ast"
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+)
+
+const (
+	CodeMissingReturn     jack_tokenizer.Code = "missing-return"
+	CodeReturnValue       jack_tokenizer.Code = "return-value"
+	CodeConstructorReturn jack_tokenizer.Code = "constructor-return"
+)
+
+// terminates reports whether running stmts never gets past their end,
+// because every path through them returns or loops forever.
+func terminates(stmts []jack_ast.Stmt) bool {
+	for _, stmt := range stmts {
+		if terminatesStmt(stmt) {
+			return true
+		}
+	}
+	return false
+}
+
+func terminatesStmt(stmt jack_ast.Stmt) bool {
+	switch stmt := stmt.(type) {
+	case *jack_ast.ReturnStmt:
+		return true
+	case *jack_ast.Block:
+		return terminates(stmt.Stmts)
+	case *jack_ast.IfStmt:
+		return stmt.Else != nil && terminates(stmt.Body.Stmts) && terminatesStmt(stmt.Else)
+	case *jack_ast.WhileStmt:
+		return isTrue(stmt.Cond) && !breaks(stmt.Body.Stmts)
+	case *jack_ast.ForStmt:
+		return (stmt.Cond == nil || isTrue(stmt.Cond)) && !breaks(stmt.Body.Stmts)
+	}
+	return false
+}
+
+// breaks reports whether stmts, the body of a loop, break out of it.
+func breaks(stmts []jack_ast.Stmt) bool {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *jack_ast.BreakStmt:
+			return true
+		case *jack_ast.Block:
+			if breaks(stmt.Stmts) {
+				return true
+			}
+		case *jack_ast.IfStmt:
+			if breaks(stmt.Body.Stmts) || stmt.Else != nil && breaks([]jack_ast.Stmt{stmt.Else}) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isTrue reports whether expr is the constant true.
+func isTrue(expr jack_ast.Expr) bool {
+	switch expr := expr.(type) {
+	case *jack_ast.KeywordLit:
+		return expr.Keyword == jack_tokenizer.KW_TRUE
+	case *jack_ast.ParenExpr:
+		return isTrue(expr.X)
+	}
+	return false
+}
+
+// isThis reports whether expr is this.
+func isThis(expr jack_ast.Expr) bool {
+	lit, ok := expr.(*jack_ast.KeywordLit)
+	return ok && lit.Keyword == jack_tokenizer.KW_THIS
+}
@@ -1,6 +1,11 @@
 package jack_compiler
 
-import jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+import (
+	"fmt"
+	"sort"
+
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+)
 
 type Name string
 
@@ -29,7 +34,7 @@ var fieldtoSegment = map[FieldType]SegmentType{
 
 var constructorTTtoFT = map[jack_tokenizer.TokenSubtype]FieldType{
 	jack_tokenizer.KW_STATIC: STATIC_F,
-	jack_tokenizer.KW_FIELD:  VAR,
+	jack_tokenizer.KW_FIELD:  FIELD,
 }
 
 var subtypeToOp = map[jack_tokenizer.TokenSubtype]ArithmeticType{
@@ -102,11 +107,18 @@ func (sym *SymbolTable) Reset() {
 	sym.varIndex = 0
 }
 
-func (sym *SymbolTable) Define(n Name, t string, kind FieldType) {
+// Define adds n to the table. It fails, leaving the table as it was, if
+// n is already defined.
+func (sym *SymbolTable) Define(n Name, t string, kind FieldType) error {
+	if sym.find(n) != nil {
+		return fmt.Errorf("%s redeclared", n)
+	}
 	table, index := sym.getTable(kind)
 
 	(*table)[n] = tableentry{*index, t}
 	*index += 1
+
+	return nil
 }
 
 func (sym *SymbolTable) VarCount(kind FieldType) int {
@@ -135,18 +147,41 @@ func (sym *SymbolTable) KindOf(n Name) FieldType {
 	return NONE
 }
 
+// TypeOf returns the type of n, or "" if n is not in the table.
 func (sym *SymbolTable) TypeOf(n Name) string {
 	table := sym.find(n)
+	if table == nil {
+		return ""
+	}
 
 	return (*table)[n].typing
 }
 
+// IndexOf returns the index of n, or -1 if n is not in the table.
 func (sym *SymbolTable) IndexOf(n Name) int {
 	table := sym.find(n)
+	if table == nil {
+		return -1
+	}
 
 	return (*table)[n].index
 }
 
+// Names returns the names of the given kind in the order they were
+// defined.
+func (sym *SymbolTable) Names(kind FieldType) []Name {
+	table, _ := sym.getTable(kind)
+	names := make([]Name, 0, len(*table))
+	for n := range *table {
+		names = append(names, n)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return (*table)[names[i]].index < (*table)[names[j]].index
+	})
+
+	return names
+}
+
 func NewSymbolTable() *SymbolTable {
 	return &SymbolTable{}
 }
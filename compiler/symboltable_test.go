@@ -0,0 +1,39 @@
+package jack_compiler
+
+import "testing"
+
+func TestSymbolTable(t *testing.T) {
+	st := NewSymbolTable()
+	st.Reset()
+	st.Define("x", "int", VAR)
+	st.Define("y", "Point", VAR)
+	st.Define("n", "int", ARG)
+
+	tests := []struct {
+		name  Name
+		kind  FieldType
+		typ   string
+		index int
+	}{
+		{"x", VAR, "int", 0},
+		{"y", VAR, "Point", 1},
+		{"n", ARG, "int", 0},
+		{"z", NONE, "", -1},
+	}
+	for _, tt := range tests {
+		if kind, typ, index := st.KindOf(tt.name), st.TypeOf(tt.name), st.IndexOf(tt.name); kind != tt.kind || typ != tt.typ || index != tt.index {
+			t.Errorf("%s: got %v %q %d, wanted %v %q %d", tt.name, kind, typ, index, tt.kind, tt.typ, tt.index)
+		}
+	}
+
+	if names := st.Names(VAR); len(names) != 2 || names[0] != "x" || names[1] != "y" {
+		t.Errorf("got names %v, wanted [x y]", names)
+	}
+
+	if err := st.Define("x", "char", ARG); err == nil {
+		t.Errorf("got %v, wanted an error redefining x", err)
+	}
+	if typ, index := st.TypeOf("x"), st.IndexOf("x"); typ != "int" || index != 0 {
+		t.Errorf("got %q %d, wanted the first x", typ, index)
+	}
+}
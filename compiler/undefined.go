@@ -0,0 +1,105 @@
+package jack_compiler
+
+import (
+	"sort"
+
+	jack_ast "github.com/renojcpp/n2t-compiler/ast"
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+)
+
+const CodeUndefined jack_tokenizer.Code = "undefined"
+
+// undefined reports a name that is not defined, suggesting the closest
+// of the names in scope: variables and constants for a value, or
+// variables and classes for the receiver of a call, which is reported
+// as an unknown class.
+func (s *compiler) undefined(name *jack_ast.Ident, receiver bool) {
+	var candidates []string
+	for _, kind := range []FieldType{VAR, ARG} {
+		for _, n := range s.subroutineSt.Names(kind) {
+			candidates = append(candidates, string(n))
+		}
+	}
+	for _, kind := range []FieldType{FIELD, STATIC_F} {
+		for _, n := range s.classSt.Names(kind) {
+			candidates = append(candidates, string(n))
+		}
+	}
+
+	var more []string
+	if receiver {
+		for class := range s.program.classes {
+			more = append(more, class)
+		}
+	} else if info, ok := s.program.classes[s.className]; ok {
+		for c := range info.consts {
+			more = append(more, c)
+		}
+	}
+	sort.Strings(more)
+	candidates = append(candidates, more...)
+
+	code, what := CodeUndefined, "variable"
+	if receiver {
+		code, what = CodeUnknownClass, "variable or class"
+	}
+	if near := nearest(name.Name, candidates); near != "" {
+		s.errorf(name.Pos(), code, "undefined %s %s, did you mean %s?", what, name.Name, near)
+		return
+	}
+	s.errorf(name.Pos(), code, "undefined %s %s", what, name.Name)
+}
+
+// nearest returns the first of candidates closest to name by edit
+// distance, or "" if none is close enough to be a likely typo.
+func nearest(name string, candidates []string) string {
+	limit := len(name) / 3
+	if limit < 1 {
+		limit = 1
+	}
+
+	best, bestDistance := "", limit+1
+	for _, c := range candidates {
+		if d := editDistance(name, c); d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+
+	return best
+}
+
+// editDistance returns the number of single byte insertions,
+// deletions, substitutions and swaps of neighbours that turn a into b.
+func editDistance(a, b string) int {
+	// rows i-2, i-1 and i of the table
+	rows := [3][]int{make([]int, len(b)+1), make([]int, len(b)+1), make([]int, len(b)+1)}
+	for j := range rows[1] {
+		rows[1][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		prev2, prev, cur := rows[0], rows[1], rows[2]
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if d := prev[j] + 1; d < cur[j] {
+				cur[j] = d
+			}
+			if d := cur[j-1] + 1; d < cur[j] {
+				cur[j] = d
+			}
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				if d := prev2[j-2] + 1; d < cur[j] {
+					cur[j] = d
+				}
+			}
+		}
+		rows = [3][]int{prev, cur, prev2}
+	}
+
+	return rows[1][len(b)]
+}
@@ -11,7 +11,7 @@ type VMWriter struct {
 
 var sm = map[SegmentType]string{
 	CONSTANT: "constant",
-	ARGUMENT: "arg",
+	ARGUMENT: "argument",
 	LOCAL:    "local",
 	STATIC_S: "static",
 	THIS:     "this",
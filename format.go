@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	jack_format "github.com/renojcpp/n2t-compiler/format"
+)
+
+func runFormat(args []string) int {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := fs.Bool("w", false, "write the result back to the source file")
+	list := fs.Bool("l", false, "list the files whose formatting differs")
+	diff := fs.Bool("d", false, "show how the formatting differs")
+	options := lexerFlags(fs)
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "cannot use -w with standard input")
+			return 2
+		}
+
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		res, err := jack_format.Source(src, options("<standard input>")...)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		report("<standard input>", src, res, *list, *diff)
+		return 0
+	}
+
+	files, err := jackFiles(fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	status := 0
+	for _, name := range files {
+		info, err := os.Stat(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		src, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open file: %s\n", name)
+			status = 1
+			continue
+		}
+
+		res, err := jack_format.Source(src, options(filepath.Base(name))...)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+
+		if *write && !bytes.Equal(src, res) {
+			if err := os.WriteFile(name, res, info.Mode().Perm()); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				status = 1
+				continue
+			}
+		}
+		if *write && !*list && !*diff {
+			continue
+		}
+		report(name, src, res, *list, *diff)
+	}
+
+	return status
+}
+
+// report prints the formatted source, or with -l or -d, the name of a
+// file that needs formatting and what would change.
+func report(name string, src, res []byte, list, diff bool) {
+	if !list && !diff {
+		os.Stdout.Write(res)
+		return
+	}
+	if bytes.Equal(src, res) {
+		return
+	}
+
+	if list {
+		fmt.Println(name)
+	}
+	if diff {
+		os.Stdout.Write(jack_format.Diff(name+".orig", src, name, res))
+	}
+}
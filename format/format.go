@@ -0,0 +1,485 @@
+// Package jack_format prints Jack source in one canonical layout.
+//
+// Declarations and statements go on lines of their own, indented by
+// four spaces per block, with opening braces at the end of the line
+// that starts the block. Binary operators and '=' are surrounded by
+// single spaces; commas are followed by one. Comments are kept where
+// they were, and a run of blank lines between two lines becomes a
+// single blank line. Formatting formatted source changes nothing.
+package jack_format
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	jack_ast "github.com/renojcpp/n2t-compiler/ast"
+	jack_parser "github.com/renojcpp/n2t-compiler/parser"
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+)
+
+const indentUnit = "    "
+
+// Source formats the Jack class in src. The options are passed on to the
+// tokenizer, so source using a language extension needs the matching
+// option: WithExtendedLiterals, WithEscapes, WithExtendedStatements,
+// WithCompoundAssignment or WithConstants. The parser accepts the
+// extended statements, compound assignments and constants whenever the
+// tokenizer lexes them, since their layout is the same either way.
+// Source fails if src has syntax errors.
+func Source(src []byte, opts ...jack_tokenizer.Option) ([]byte, error) {
+	opts = append(opts, jack_tokenizer.WithTrivia())
+	tokens, err := jack_tokenizer.Tokenize(bytes.NewReader(src), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	class, err := jack_parser.ParseFile(jack_tokenizer.NewSliceStream(tokens), jack_parser.WithExtendedStatements(), jack_parser.WithCompoundAssignment(), jack_parser.WithConstants())
+	if err != nil {
+		return nil, err
+	}
+
+	p := &printer{tokens: tokens, ws: wsNewline, noBlank: true}
+	p.class(class)
+	p.end()
+
+	// the layout comes from the tree and the text from the tokens, so
+	// make sure the two went through the input in step
+	formatted, err := jack_tokenizer.Tokenize(bytes.NewReader(p.out.Bytes()), opts...)
+	if err != nil || !sameTokens(tokens, formatted) {
+		return nil, errors.New("internal error: formatting changed the tokens of the class")
+	}
+
+	return p.out.Bytes(), nil
+}
+
+func sameTokens(a, b []jack_tokenizer.Token) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Raw != b[i].Raw || a[i].Tokentype != b[i].Tokentype {
+			return false
+		}
+	}
+	return true
+}
+
+// whitespace is what the printer owes the output before the next token
+// or comment.
+type whitespace int
+
+const (
+	wsNone    whitespace = iota
+	wsSpace              // a single space
+	wsNewline            // a new line at the indentation of the block
+	wsBreak              // a new line in the middle of a statement, indented one step further
+	wsComment            // a space after a block comment, left out before a closing token
+)
+
+type printer struct {
+	out    bytes.Buffer
+	tokens []jack_tokenizer.Token // the input, with trivia
+	next   int                    // index of the next token to print
+
+	indent  int
+	ws      whitespace
+	noBlank bool // no blank line may come next, as at the top of a block
+}
+
+func (p *printer) space() {
+	if p.ws == wsNone || p.ws == wsComment {
+		p.ws = wsSpace
+	}
+}
+
+func (p *printer) newline() {
+	p.ws = wsNewline
+}
+
+// flush writes the whitespace owed, with a blank line in front of a new
+// line if blank is set and one is allowed there.
+func (p *printer) flush(blank bool) {
+	switch p.ws {
+	case wsSpace, wsComment:
+		p.out.WriteByte(' ')
+	case wsNewline, wsBreak:
+		if p.out.Len() > 0 {
+			p.out.WriteByte('\n')
+			if blank && p.ws == wsNewline && !p.noBlank {
+				p.out.WriteByte('\n')
+			}
+		}
+		depth := p.indent
+		if p.ws == wsBreak {
+			depth++
+		}
+		p.out.WriteString(strings.Repeat(indentUnit, depth))
+		p.noBlank = false
+	}
+	p.ws = wsNone
+}
+
+// lineBreaks counts the line breaks in s, where "\r\n", "\n" and "\r"
+// each end a line.
+func lineBreaks(s string) int {
+	return strings.Count(s, "\n") + strings.Count(s, "\r") - strings.Count(s, "\r\n")
+}
+
+// comments prints the comments in leading trivia, each on a line of
+// its own, and reports whether a blank line came before what follows
+// them.
+func (p *printer) comments(leading []jack_tokenizer.Trivia) bool {
+	breaks := 0
+	for _, tr := range leading {
+		switch tr.Kind {
+		case jack_tokenizer.TRIVIA_SPACE:
+		case jack_tokenizer.TRIVIA_NEWLINE:
+			breaks += lineBreaks(tr.Text)
+		default:
+			if p.ws != wsNewline {
+				p.ws = wsBreak
+			}
+			ws := p.ws
+			p.flush(breaks > 1)
+			p.out.WriteString(tr.Text)
+			p.ws = ws
+			breaks = 0
+		}
+	}
+
+	return breaks > 1
+}
+
+// trailing prints the comments following a token on its line.
+func (p *printer) trailing(trivia []jack_tokenizer.Trivia) {
+	for _, tr := range trivia {
+		switch tr.Kind {
+		case jack_tokenizer.TRIVIA_LINE_COMMENT:
+			p.out.WriteString(" " + tr.Text)
+			p.ws = wsBreak
+		case jack_tokenizer.TRIVIA_BLOCK_COMMENT, jack_tokenizer.TRIVIA_DOC_COMMENT:
+			p.out.WriteString(" " + tr.Text)
+			p.ws = wsComment
+		}
+	}
+}
+
+func (p *printer) take() jack_tokenizer.Token {
+	token := p.tokens[p.next]
+	p.next++
+	return token
+}
+
+func (p *printer) emit(token jack_tokenizer.Token, blank bool) {
+	if p.ws == wsComment && closing(token) {
+		p.ws = wsNone
+	}
+	p.flush(blank)
+	p.out.WriteString(token.Raw)
+	p.trailing(token.Trailing)
+}
+
+// closing reports whether token ends a list or statement, and so goes
+// right after what comes before it.
+func closing(token jack_tokenizer.Token) bool {
+	switch token.Raw {
+	case ")", "]", ",", ";":
+		return true
+	}
+	return false
+}
+
+// token prints the next token of the input along with its comments.
+func (p *printer) token() {
+	token := p.take()
+	p.emit(token, p.comments(token.Leading))
+}
+
+// commentsAhead reports whether comments come before the next token.
+func (p *printer) commentsAhead() bool {
+	for _, tr := range p.tokens[p.next].Leading {
+		if tr.Kind != jack_tokenizer.TRIVIA_SPACE && tr.Kind != jack_tokenizer.TRIVIA_NEWLINE {
+			return true
+		}
+	}
+	return false
+}
+
+// open prints the '{' starting a block and indents what follows.
+func (p *printer) open() {
+	p.space()
+	p.token()
+	p.indent++
+	p.noBlank = true
+}
+
+// close prints the '}' ending a block on a line of its own. Comments in
+// front of it still belong inside the block.
+func (p *printer) close() {
+	p.newline()
+	token := p.take()
+	p.comments(token.Leading)
+	p.indent--
+	p.newline()
+	p.emit(token, false)
+}
+
+// end prints the comments after the class, which the lexer hands over
+// with the end of file.
+func (p *printer) end() {
+	if p.next < len(p.tokens) {
+		p.newline()
+		p.comments(p.tokens[p.next].Leading)
+		p.next++
+	}
+	p.out.WriteByte('\n')
+}
+
+// Declarations
+
+func (p *printer) class(class *jack_ast.Class) {
+	p.token() // class
+	p.space()
+	p.token()
+	p.open()
+	for _, dec := range class.Decls() {
+		p.newline()
+		switch dec := dec.(type) {
+		case *jack_ast.ClassVarDec:
+			p.token() // static or field
+			p.space()
+			p.token()
+			p.space()
+			p.names(len(dec.Names))
+			p.token() // ;
+		case *jack_ast.ConstDec:
+			p.token() // const
+			p.space()
+			p.token()
+			p.space()
+			p.token()
+			p.space()
+			p.token() // =
+			p.space()
+			p.expression(dec.Value)
+			p.token() // ;
+		case *jack_ast.EnumDec:
+			p.token() // enum
+			p.space()
+			p.token()
+			p.space()
+			p.token() // {
+			p.space()
+			p.names(len(dec.Members))
+			p.space()
+			p.token() // }
+		}
+	}
+	for _, dec := range class.Subroutines {
+		p.newline()
+		p.subroutine(dec)
+	}
+	p.close()
+}
+
+// names prints a comma separated list of n names.
+func (p *printer) names(n int) {
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			p.token() // ,
+			p.space()
+		}
+		p.token()
+	}
+}
+
+func (p *printer) subroutine(dec *jack_ast.SubroutineDec) {
+	p.token() // constructor, function or method
+	p.space()
+	p.token()
+	p.space()
+	p.token()
+	p.token() // (
+	for i := range dec.Params {
+		if i > 0 {
+			p.token() // ,
+			p.space()
+		}
+		p.token()
+		p.space()
+		p.token()
+	}
+	p.token() // )
+
+	p.open()
+	for _, v := range dec.Body.Vars {
+		p.newline()
+		p.token() // var
+		p.space()
+		p.token()
+		p.space()
+		p.names(len(v.Names))
+		p.token() // ;
+	}
+	p.statements(dec.Body.Stmts)
+	p.close()
+}
+
+// Statements
+
+func (p *printer) statements(stmts []jack_ast.Stmt) {
+	for _, stmt := range stmts {
+		p.newline()
+		p.statement(stmt)
+	}
+}
+
+func (p *printer) block(block *jack_ast.Block) {
+	p.open()
+	p.statements(block.Stmts)
+	p.close()
+}
+
+// condition prints the parenthesized condition of an if or while.
+func (p *printer) condition(cond jack_ast.Expr) {
+	p.space()
+	p.token() // (
+	p.expression(cond)
+	p.token() // )
+}
+
+func (p *printer) statement(stmt jack_ast.Stmt) {
+	switch stmt := stmt.(type) {
+	case *jack_ast.Block:
+		p.block(stmt)
+	case *jack_ast.LetStmt:
+		p.token() // let
+		p.space()
+		p.token()
+		if stmt.Index != nil {
+			p.token() // [
+			p.expression(stmt.Index)
+			p.token() // ]
+		}
+		p.space()
+		p.token() // =
+		p.space()
+		p.expression(stmt.Value)
+		p.semicolon(stmt.Semicolon)
+	case *jack_ast.IfStmt:
+		p.token() // if
+		p.condition(stmt.Cond)
+		p.block(stmt.Body)
+		if stmt.Else != nil {
+			// a comment in front of else, or after the '}' before it,
+			// moves it to a line of its own
+			if p.commentsAhead() || p.ws == wsBreak {
+				p.newline()
+			} else {
+				p.space()
+			}
+			p.token() // else
+			p.space()
+			p.statement(stmt.Else)
+		}
+	case *jack_ast.WhileStmt:
+		p.token() // while
+		p.condition(stmt.Cond)
+		p.block(stmt.Body)
+	case *jack_ast.DoStmt:
+		p.token() // do
+		p.space()
+		p.expression(stmt.Call)
+		p.semicolon(stmt.Semicolon)
+	case *jack_ast.ReturnStmt:
+		p.token() // return
+		if stmt.Value != nil {
+			p.space()
+			p.expression(stmt.Value)
+		}
+		p.token() // ;
+	case *jack_ast.ForStmt:
+		p.token() // for
+		p.space()
+		p.token() // (
+		if stmt.Init != nil {
+			p.statement(stmt.Init) // with its ;
+		} else {
+			p.token() // ;
+		}
+		if stmt.Cond != nil {
+			p.space()
+			p.expression(stmt.Cond)
+		}
+		p.token() // ;
+		if stmt.Post != nil {
+			p.space()
+			p.statement(stmt.Post)
+		}
+		p.token() // )
+		p.block(stmt.Body)
+	case *jack_ast.BreakStmt, *jack_ast.ContinueStmt:
+		p.token() // break or continue
+		p.token() // ;
+	default:
+		panic(fmt.Sprintf("jack_format: unexpected statement %T", stmt))
+	}
+}
+
+// semicolon prints the ';' ending a let or do statement, which the
+// clauses in the header of a for loop go without.
+func (p *printer) semicolon(pos jack_tokenizer.Position) {
+	if pos.IsValid() {
+		p.token()
+	}
+}
+
+// Expressions
+
+func (p *printer) expression(expr jack_ast.Expr) {
+	switch expr := expr.(type) {
+	case *jack_ast.Ident, *jack_ast.IntLit, *jack_ast.StringLit, *jack_ast.KeywordLit:
+		p.token()
+	case *jack_ast.ParenExpr:
+		p.token() // (
+		p.expression(expr.X)
+		p.token() // )
+	case *jack_ast.UnaryExpr:
+		p.token()
+		p.expression(expr.X)
+	case *jack_ast.BinaryExpr:
+		p.expression(expr.X)
+		p.space()
+		p.token()
+		p.space()
+		p.expression(expr.Y)
+	case *jack_ast.IndexExpr:
+		p.token()
+		p.token() // [
+		p.expression(expr.Index)
+		p.token() // ]
+	case *jack_ast.CallExpr:
+		if expr.Receiver != nil {
+			p.token()
+			p.token() // .
+		}
+		p.token()
+		p.token() // (
+		for i, arg := range expr.Args {
+			if i > 0 {
+				p.token() // ,
+				p.space()
+			}
+			p.expression(arg)
+		}
+		p.token() // )
+	case *jack_ast.SelectorExpr:
+		p.token()
+		p.token() // .
+		p.token()
+	default:
+		panic(fmt.Sprintf("jack_format: unexpected expression %T", expr))
+	}
+}
@@ -0,0 +1,187 @@
+package jack_format
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"empty class", "class Main{}", "class Main {\n}\n"},
+		{
+			"spacing",
+			"class Main{function int f(int a,int b){var int x,y;let x[a]=-a+(b*~a);do Output.printInt(x[0],y);return x;}}",
+			"class Main {\n    function int f(int a, int b) {\n        var int x, y;\n        let x[a] = -a + (b * ~a);\n        do Output.printInt(x[0], y);\n        return x;\n    }\n}\n",
+		},
+		{
+			"blank lines",
+			"\n\nclass Main {\n\n\n  static int a;\n\n\n\n  static int b;\n\n}\n\n\n",
+			"class Main {\n    static int a;\n\n    static int b;\n}\n",
+		},
+		{
+			"else on the closing brace line",
+			"class Main { function void f() { if (a) { return; }\n else\n { return; } } }",
+			"class Main {\n    function void f() {\n        if (a) {\n            return;\n        } else {\n            return;\n        }\n    }\n}\n",
+		},
+		{
+			"comment before else",
+			"class Main { function void f() { if (a) { }\n// b\nelse { } } }",
+			"class Main {\n    function void f() {\n        if (a) {\n        }\n        // b\n        else {\n        }\n    }\n}\n",
+		},
+		{
+			"comment after the brace before else",
+			"class Main { function void f() { if (a) { let a = 2; } // after if\n else { let a = 3; } } }",
+			"class Main {\n    function void f() {\n        if (a) {\n            let a = 2;\n        } // after if\n        else {\n            let a = 3;\n        }\n    }\n}\n",
+		},
+		{
+			"comment inside an expression",
+			"class Main { function int f() { return 1 +\n// two\n2; } }",
+			"class Main {\n    function int f() {\n        return 1 +\n            // two\n            2;\n    }\n}\n",
+		},
+		{
+			"block comments",
+			"class Main { function void f(/* none */) { do g(/* a */ 1, 2 /* b */); } }",
+			"class Main {\n    function void f( /* none */) {\n        do g( /* a */ 1, 2 /* b */);\n    }\n}\n",
+		},
+		{
+			"block comment before a closing paren",
+			"class Main { function void f() { do Output.printInt(a /* inline */); let b[a /* i */] = a /* x */; } }",
+			"class Main {\n    function void f() {\n        do Output.printInt(a /* inline */);\n        let b[a /* i */] = a /* x */;\n    }\n}\n",
+		},
+		{
+			"extended statements",
+			"class Main { function void f() { for(let i=0;i<3;let i=i+1){if(i=1){continue;}else if(i=2){break;}} for(;;){break;} } }",
+			"class Main {\n    function void f() {\n        for (let i = 0; i < 3; let i = i + 1) {\n            if (i = 1) {\n                continue;\n            } else if (i = 2) {\n                break;\n            }\n        }\n        for (;;) {\n            break;\n        }\n    }\n}\n",
+		},
+		{"crlf", "class Main {\r\n// x\r\n}\r\n", "class Main {\n    // x\n}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Source([]byte(tt.src))
+			if err != nil {
+				t.Fatalf("failed to format: %s", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got\n%s\nwanted\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourceGolden(t *testing.T) {
+	src, err := os.ReadFile("testdata/messy.jack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("testdata/messy.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Source(src)
+	if err != nil {
+		t.Fatalf("failed to format: %s", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("got\n%s\nwanted\n%s", got, want)
+	}
+}
+
+func TestSourceIsIdempotent(t *testing.T) {
+	files, _ := filepath.Glob("../parser/testdata/*/*.jack")
+	files = append(files, "testdata/messy.jack")
+	for _, name := range files {
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			once, err := Source(src)
+			if err != nil {
+				t.Fatalf("failed to format: %s", err)
+			}
+			twice, err := Source(once)
+			if err != nil {
+				t.Fatalf("failed to format again: %s", err)
+			}
+			if string(once) != string(twice) {
+				t.Errorf("formatting twice changed\n%s\ninto\n%s", once, twice)
+			}
+		})
+	}
+}
+
+func TestSourceOptions(t *testing.T) {
+	src := "class Main { function char f() { return 'a' + 0x10; } }"
+	if _, err := Source([]byte(src)); err == nil {
+		t.Errorf("got no error, wanted one for literals without the option")
+	}
+
+	got, err := Source([]byte(src), jack_tokenizer.WithExtendedLiterals())
+	if err != nil {
+		t.Fatalf("failed to format: %s", err)
+	}
+	if want := "        return 'a' + 0x10;\n"; !strings.Contains(string(got), want) {
+		t.Errorf("got\n%s\nwanted a line %q", got, want)
+	}
+
+	src = "class Main { function void f() { let a[i]+=1; } }"
+	got, err = Source([]byte(src), jack_tokenizer.WithCompoundAssignment())
+	if err != nil {
+		t.Fatalf("failed to format: %s", err)
+	}
+	if want := "        let a[i] += 1;\n"; !strings.Contains(string(got), want) {
+		t.Errorf("got\n%s\nwanted a line %q", got, want)
+	}
+
+	src = "class Main { const int MAX=512; enum Dir{UP,DOWN} function int f() { return Game.MAX+Dir.UP; } }"
+	got, err = Source([]byte(src), jack_tokenizer.WithConstants())
+	if err != nil {
+		t.Fatalf("failed to format: %s", err)
+	}
+	for _, want := range []string{"    const int MAX = 512;\n", "    enum Dir { UP, DOWN }\n", "        return Game.MAX + Dir.UP;\n"} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("got\n%s\nwanted a line %q", got, want)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want string
+	}{
+		{"equal", "a\n", "a\n", ""},
+		{"change", "a\nb\nc\n", "a\nB\nc\n", "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"},
+		{"insert at start", "b\n", "a\nb\n", "@@ -1,1 +1,2 @@\n+a\n b\n"},
+		{
+			"two hunks",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			"one\n2\n3\n4\n5\n6\n7\n8\n9\n10\neleven\n",
+			"@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -8,4 +8,4 @@\n 8\n 9\n 10\n-11\n+eleven\n",
+		},
+		{"missing newline", "a", "a\n", "@@ -1,1 +1,1 @@\n-a\n\\ No newline at end of file\n+a\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(Diff("old", []byte(tt.old), "new", []byte(tt.new)))
+			if tt.want != "" {
+				tt.want = "--- old\n+++ new\n" + tt.want
+			}
+			if got != tt.want {
+				t.Errorf("got\n%s\nwanted\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package jack_format
+
+import (
+	"testing"
+
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+)
+
+// fuzzOptions switches on a language extension for each bit set in
+// flags, so that the fuzzer explores every dialect.
+func fuzzOptions(flags uint8) []jack_tokenizer.Option {
+	var opts []jack_tokenizer.Option
+	if flags&1 != 0 {
+		opts = append(opts, jack_tokenizer.WithExtendedStatements())
+	}
+	if flags&2 != 0 {
+		opts = append(opts, jack_tokenizer.WithCompoundAssignment())
+	}
+	if flags&4 != 0 {
+		opts = append(opts, jack_tokenizer.WithConstants())
+	}
+	if flags&8 != 0 {
+		opts = append(opts, jack_tokenizer.WithEscapes(), jack_tokenizer.WithExtendedLiterals())
+	}
+
+	return opts
+}
+
+// FuzzSource checks that formatting never panics and that formatted
+// source is left alone by a second pass.
+func FuzzSource(f *testing.F) {
+	f.Fuzz(func(t *testing.T, src string, flags uint8) {
+		opts := fuzzOptions(flags)
+		once, err := Source([]byte(src), opts...)
+		if err != nil {
+			return
+		}
+
+		twice, err := Source(once, opts...)
+		if err != nil {
+			t.Fatalf("formatted source does not format: %s\n%s", err, once)
+		}
+		if string(once) != string(twice) {
+			t.Fatalf("formatting twice changed\n%s\ninto\n%s", once, twice)
+		}
+	})
+}
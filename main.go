@@ -1,45 +1,147 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
-	jack_compiler "github.com/renojcpp/n2t-compiler/compiler"
+	jack_parser "github.com/renojcpp/n2t-compiler/parser"
 	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
 )
 
+const usage = `usage: n2t-compiler [command] [flags] path...
+
+commands:
+  compile   compile every .jack file to VM code (the default)
+  fmt       print every .jack file in the canonical layout
+  parse     write the parse tree of every Foo.jack to Foo.xml or Foo.json
+  tokenize  print the tokens of every .jack file
+
+Run "n2t-compiler <command> -h" for the flags of a command.
+`
+
 func main() {
 	args := os.Args[1:]
+	command := "compile"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		if _, ok := commands[args[0]]; ok {
+			command = args[0]
+			args = args[1:]
+		}
+	}
+	if len(args) > 0 && (args[0] == "help" || args[0] == "--help") {
+		fmt.Fprint(os.Stderr, usage)
+		return
+	}
+
+	os.Exit(commands[command](args))
+}
+
+var commands = map[string]func([]string) int{
+	"compile":  runCompile,
+	"fmt":      runFormat,
+	"parse":    runParse,
+	"tokenize": runTokenize,
+}
+
+// lexerFlags registers the flags that switch on language extensions in
+// the tokenizer, and returns a function building the matching options
+// for a file.
+func lexerFlags(fs *flag.FlagSet) func(filename string) []jack_tokenizer.Option {
+	escapes := fs.Bool("escapes", false, "allow \\n, \\t, \\\", \\\\ and \\u{NNN} escapes in string constants")
+	literals := fs.Bool("literals", false, "allow hexadecimal, binary and character literals")
+	statements := fs.Bool("statements", false, "allow else if, for loops, break and continue")
+	compound := fs.Bool("compound", false, "allow let statements assigning with +=, -=, *=, /=, &= and |=")
+	constants := fs.Bool("constants", false, "allow class constants and enums")
+
+	return func(filename string) []jack_tokenizer.Option {
+		opts := []jack_tokenizer.Option{jack_tokenizer.WithFilename(filename)}
+		if *escapes {
+			opts = append(opts, jack_tokenizer.WithEscapes())
+		}
+		if *literals {
+			opts = append(opts, jack_tokenizer.WithExtendedLiterals())
+		}
+		if *statements {
+			opts = append(opts, jack_tokenizer.WithExtendedStatements())
+		}
+		if *compound {
+			opts = append(opts, jack_tokenizer.WithCompoundAssignment())
+		}
+		if *constants {
+			opts = append(opts, jack_tokenizer.WithConstants())
+		}
+
+		return opts
+	}
+}
+
+// parserFlags registers the flags that switch on language extensions in
+// the parser, and returns a function building the matching options. It
+// goes after lexerFlags, whose -statements, -compound and -constants
+// flags it shares.
+func parserFlags(fs *flag.FlagSet) func() []jack_parser.Option {
+	precedence := fs.Bool("precedence", false, "parse expressions with conventional operator precedence instead of left to right")
+	statements := fs.Lookup("statements").Value.(flag.Getter)
+	compound := fs.Lookup("compound").Value.(flag.Getter)
+	constants := fs.Lookup("constants").Value.(flag.Getter)
+
+	return func() []jack_parser.Option {
+		var opts []jack_parser.Option
+		if *precedence {
+			opts = append(opts, jack_parser.WithPrecedence())
+		}
+		if statements.Get().(bool) {
+			opts = append(opts, jack_parser.WithExtendedStatements())
+		}
+		if compound.Get().(bool) {
+			opts = append(opts, jack_parser.WithCompoundAssignment())
+		}
+		if constants.Get().(bool) {
+			opts = append(opts, jack_parser.WithConstants())
+		}
+
+		return opts
+	}
+}
 
-	for _, arg := range args {
-		dirs, err := os.ReadDir(arg)
+// reportWarnings prints the warnings among diags. Errors are left to
+// the caller, which gets them back as the error of the failed step.
+func reportWarnings(diags jack_tokenizer.Diagnostics) {
+	for _, diag := range diags {
+		if diag.Severity == jack_tokenizer.SeverityWarning {
+			fmt.Fprintln(os.Stderr, diag)
+		}
+	}
+}
+
+// jackFiles expands the command line paths into .jack files. A
+// directory stands for the .jack files directly inside it.
+func jackFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
 		if err != nil {
-			fmt.Printf("no such directory: %s", arg)
+			return nil, fmt.Errorf("no such file or directory: %s", path)
 		}
 
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		dirs, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
 		for _, entry := range dirs {
-			if strings.HasSuffix(entry.Name(), ".jack") {
-				file, err := os.Open(fmt.Sprintf("%s/%s", arg, entry.Name()))
-				if err != nil {
-					fmt.Printf("failed to open file: %s", arg)
-				}
-
-				tokens, err := jack_tokenizer.Tokenize(file)
-
-				if err != nil {
-					fmt.Printf("failed to tokenize: %s", err)
-				}
-
-				toOut := jack_compiler.ParseGrammar(tokens)
-				fmt.Println("outputting")
-				f, _ := os.Create(fmt.Sprintf("%s/%s", arg, entry.Name()+".vm"))
-				err = toOut(f)
-
-				if err != nil {
-					fmt.Printf("%s", err.Error())
-				}
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".jack") {
+				files = append(files, filepath.Join(path, entry.Name()))
 			}
 		}
 	}
+
+	return files, nil
 }
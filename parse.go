@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	jack_ast "github.com/renojcpp/n2t-compiler/ast"
+	jack_parser "github.com/renojcpp/n2t-compiler/parser"
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+)
+
+var treeWriters = map[string]func(io.Writer, *jack_ast.Class) error{
+	"xml":  jack_parser.WriteXML,
+	"json": jack_parser.WriteJSON,
+}
+
+func runParse(args []string) int {
+	fs := flag.NewFlagSet("parse", flag.ExitOnError)
+	format := fs.String("format", "xml", "output format: xml, json")
+	options := lexerFlags(fs)
+	parserOptions := parserFlags(fs)
+	fs.Parse(args)
+
+	write, ok := treeWriters[*format]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown format: %s\n", *format)
+		return 2
+	}
+
+	files, err := jackFiles(fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	status := 0
+	for _, name := range files {
+		file, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open file: %s\n", name)
+			status = 1
+			continue
+		}
+
+		parser := jack_parser.NewParser(jack_tokenizer.NewLexer(file, options(filepath.Base(name))...), parserOptions()...)
+		class, err := parser.Parse()
+		file.Close()
+		reportWarnings(parser.Diagnostics())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+
+		var out bytes.Buffer
+		if err := write(&out, class); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		// Foo.jack is written to Foo.xml, where the course's comparer
+		// looks for it, or Foo.json
+		outName := strings.TrimSuffix(name, ".jack") + "." + *format
+		if err := os.WriteFile(outName, out.Bytes(), 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+		}
+	}
+
+	return status
+}
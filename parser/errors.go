@@ -0,0 +1,181 @@
+package jack_parser
+
+import (
+	"sort"
+	"strings"
+
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+)
+
+const (
+	CodeUnexpectedToken jack_tokenizer.Code = "unexpected-token"
+	CodePrecedence      jack_tokenizer.Code = "precedence"
+	CodeDialect         jack_tokenizer.Code = "dialect"
+	CodeOutsideLoop     jack_tokenizer.Code = "outside-loop"
+)
+
+var classDecPairs = []tokenpair{
+	{jack_tokenizer.KEYWORD, jack_tokenizer.KW_STATIC},
+	{jack_tokenizer.KEYWORD, jack_tokenizer.KW_FIELD},
+	{jack_tokenizer.KEYWORD, jack_tokenizer.KW_CONSTRUCTOR},
+	{jack_tokenizer.KEYWORD, jack_tokenizer.KW_FUNCTION},
+	{jack_tokenizer.KEYWORD, jack_tokenizer.KW_METHOD},
+	{jack_tokenizer.KEYWORD, jack_tokenizer.KW_CONST},
+	{jack_tokenizer.KEYWORD, jack_tokenizer.KW_ENUM},
+}
+
+// bailout unwinds the parser from a syntax error to the nearest
+// statement or declaration, where it gets back in step with the input.
+type bailout struct{}
+
+// describe names what the grammar wanted, as in "';'" or "identifier".
+func describe(pairs []tokenpair) string {
+	var names []string
+	for _, p := range pairs {
+		switch p.tt {
+		case jack_tokenizer.KEYWORD, jack_tokenizer.SYMBOL:
+			names = append(names, "'"+p.st.Spelling()+"'")
+		case jack_tokenizer.IDENTIFIER:
+			names = append(names, "identifier")
+		}
+	}
+
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+// found names a token for the "found" half of an error message.
+func found(token *jack_tokenizer.Token) string {
+	switch token.Tokentype {
+	case jack_tokenizer.EOF:
+		return "end of file"
+	case jack_tokenizer.IDENTIFIER:
+		return "identifier " + token.Lexeme
+	case jack_tokenizer.INT_CONSTANT:
+		return "integer constant " + token.Raw
+	case jack_tokenizer.STRING_CONSTANT:
+		return "string constant " + token.Raw
+	default:
+		return "'" + token.Raw + "'"
+	}
+}
+
+// errorf records a syntax error. Only the first error at any one spot is
+// kept, and once recovery has skipped to the end of the file, running
+// into it is put down to the recovery having skipped too far.
+func (s *parser) errorf(pos jack_tokenizer.Position, code jack_tokenizer.Code, format string, args ...interface{}) {
+	for i := len(s.diags) - 1; i >= 0; i-- {
+		if s.diags[i].Severity != jack_tokenizer.SeverityError {
+			continue
+		}
+		if s.diags[i].Pos.Offset == pos.Offset || s.skipped && s.atEnd() {
+			return
+		}
+		break
+	}
+
+	s.diags = append(s.diags, jack_tokenizer.NewDiagnostic(jack_tokenizer.SeverityError, code, pos, format, args...))
+}
+
+// notInDialect reports a construct of a language extension that is not
+// switched on.
+func (s *parser) notInDialect(pos jack_tokenizer.Position, what, dialect string) {
+	s.errorf(pos, CodeDialect, "%s not standard Jack; enable the %s dialect to use it", what, dialect)
+}
+
+// warnf records a warning.
+func (s *parser) warnf(pos jack_tokenizer.Position, code jack_tokenizer.Code, format string, args ...interface{}) {
+	s.diags = append(s.diags, jack_tokenizer.NewDiagnostic(jack_tokenizer.SeverityWarning, code, pos, format, args...))
+}
+
+// expected reports that the current token does not fit the grammar and
+// bails out. Tokens the lexer already complained about are not reported
+// twice.
+func (s *parser) expected(what string) {
+	token := s.Current()
+	if token.Tokentype != jack_tokenizer.ERROR {
+		s.errorf(token.Pos, CodeUnexpectedToken, "expected %s, found %s", what, found(token))
+	}
+
+	panic(bailout{})
+}
+
+// recovering runs parse, and if it bails out, skips ahead with sync. It
+// reports whether parse finished normally. At least one token is
+// always consumed, so loops calling it keep making progress.
+func (s *parser) recovering(parse func(), sync func()) (ok bool) {
+	start := s.Current().Pos.Offset
+	defer func() {
+		if ok {
+			return
+		}
+		if r := recover(); r != (bailout{}) {
+			panic(r)
+		}
+
+		if s.Current().Pos.Offset == start && !s.atEnd() {
+			s.Advance()
+		}
+		sync()
+	}()
+
+	parse()
+	return true
+}
+
+// syncStatement skips to where the next statement can start: just past a
+// ';', or at a '}', a statement keyword or a declaration. Blocks are
+// skipped whole.
+func (s *parser) syncStatement() {
+	depth := 0
+	for !s.atEnd() {
+		switch {
+		case s.matches([]tokenpair{{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_LEFT_BRACE}}):
+			depth++
+		case s.matches([]tokenpair{{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_RIGHT_BRACE}}):
+			if depth == 0 {
+				return
+			}
+			depth--
+		case depth > 0:
+		case s.matches([]tokenpair{{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_SEMICOLON}}):
+			s.Advance()
+			return
+		case s.matches(statementPairs), s.matches(classDecPairs),
+			s.matches([]tokenpair{{jack_tokenizer.KEYWORD, jack_tokenizer.KW_VAR}}):
+			return
+		}
+		s.Advance()
+	}
+	s.skipped = true
+}
+
+// syncDeclaration skips to the next class member declaration, or to the
+// '}' closing the class.
+func (s *parser) syncDeclaration() {
+	depth := 0
+	for !s.atEnd() && !s.matches(classDecPairs) {
+		switch {
+		case s.matches([]tokenpair{{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_LEFT_BRACE}}):
+			depth++
+		case s.matches([]tokenpair{{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_RIGHT_BRACE}}):
+			if depth == 0 {
+				return
+			}
+			depth--
+		}
+		s.Advance()
+	}
+	s.skipped = s.atEnd()
+}
+
+// diagnostics returns the lexical and syntax errors in source order.
+func (s *parser) diagnostics() jack_tokenizer.Diagnostics {
+	sort.SliceStable(s.diags, func(i, j int) bool {
+		return s.diags[i].Pos.Offset < s.diags[j].Pos.Offset
+	})
+
+	return s.diags
+}
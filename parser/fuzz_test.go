@@ -0,0 +1,63 @@
+package jack_parser
+
+import (
+	"strings"
+	"testing"
+
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+)
+
+// fuzzOptions switches on a language extension for each bit set in
+// flags, in the tokenizer and the parser both, so that the fuzzer
+// explores every dialect.
+func fuzzOptions(flags uint8) ([]jack_tokenizer.Option, []Option) {
+	var lexOpts []jack_tokenizer.Option
+	var opts []Option
+	if flags&1 != 0 {
+		lexOpts = append(lexOpts, jack_tokenizer.WithExtendedStatements())
+		opts = append(opts, WithExtendedStatements())
+	}
+	if flags&2 != 0 {
+		lexOpts = append(lexOpts, jack_tokenizer.WithCompoundAssignment())
+		opts = append(opts, WithCompoundAssignment())
+	}
+	if flags&4 != 0 {
+		lexOpts = append(lexOpts, jack_tokenizer.WithConstants())
+		opts = append(opts, WithConstants())
+	}
+	if flags&8 != 0 {
+		lexOpts = append(lexOpts, jack_tokenizer.WithEscapes(), jack_tokenizer.WithExtendedLiterals())
+	}
+	if flags&16 != 0 {
+		opts = append(opts, WithPrecedence())
+	}
+
+	return lexOpts, opts
+}
+
+// FuzzParseGrammar checks that parsing never panics, and that the same
+// tokens rendered back to source give the same parse.
+func FuzzParseGrammar(f *testing.F) {
+	f.Fuzz(func(t *testing.T, src string, flags uint8) {
+		lexOpts, opts := fuzzOptions(flags)
+		parse := func(tokens []jack_tokenizer.Token) string {
+			var out strings.Builder
+			if class, err := ParseFile(jack_tokenizer.NewSliceStream(tokens), opts...); err == nil {
+				WriteXML(&out, class)
+			}
+			return out.String()
+		}
+
+		tokens, err := jack_tokenizer.Tokenize(strings.NewReader(src), lexOpts...)
+		out := parse(tokens)
+		if err != nil {
+			// tokens with lexical errors need not render back the same
+			return
+		}
+
+		again, _ := jack_tokenizer.Tokenize(strings.NewReader(jack_tokenizer.Render(tokens)), lexOpts...)
+		if rendered := parse(again); out != rendered {
+			t.Fatalf("rendered source parses differently:\n%s\n%s", out, rendered)
+		}
+	})
+}
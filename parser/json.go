@@ -0,0 +1,305 @@
+package jack_parser
+
+import (
+	"encoding/json"
+	"io"
+
+	jack_ast "github.com/renojcpp/n2t-compiler/ast"
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+)
+
+// JSONVersion is the version of the JSON format written by WriteJSON,
+// described by ast.schema.json. It goes up whenever a change could break
+// an existing reader, as a new node kind does.
+const JSONVersion = 4
+
+type jsonPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
+type jsonRange struct {
+	Start jsonPosition `json:"start"`
+	End   jsonPosition `json:"end"`
+}
+
+// jsonSymbol is what a variable name resolves to: its kind (static,
+// field, argument or local), its type and its index in the matching VM
+// segment.
+type jsonSymbol struct {
+	Kind  string `json:"kind"`
+	Type  string `json:"type"`
+	Index int    `json:"index"`
+}
+
+type jsonNode struct {
+	Kind     string      `json:"kind"`
+	Role     string      `json:"role,omitempty"`
+	Range    jsonRange   `json:"range"`
+	Value    *string     `json:"value,omitempty"`
+	Symbol   *jsonSymbol `json:"symbol,omitempty"`
+	Children []*jsonNode `json:"children,omitempty"`
+}
+
+type jsonFile struct {
+	Version int       `json:"version"`
+	File    string    `json:"file,omitempty"`
+	Class   *jsonNode `json:"class"`
+}
+
+type jsonWriter struct {
+	name  string
+	class map[string]*jsonSymbol
+	local map[string]*jsonSymbol
+}
+
+// WriteJSON writes the tree of class as a single JSON document in the
+// format described by ast.schema.json. Every node carries its kind, its
+// role in the parent, its source range and its children; names of
+// variables also carry the symbol they resolve to.
+func WriteJSON(w io.Writer, class *jack_ast.Class) error {
+	j := &jsonWriter{class.Name.Name, map[string]*jsonSymbol{}, nil}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(jsonFile{JSONVersion, class.Pos().File, j.classNode(class)})
+}
+
+func toJSONRange(n jack_ast.Node) jsonRange {
+	start, end := n.Pos(), n.End()
+	return jsonRange{
+		jsonPosition{start.Line, start.Column, start.Offset},
+		jsonPosition{end.Line, end.Column, end.Offset},
+	}
+}
+
+func newJSONNode(kind string, n jack_ast.Node, children ...*jsonNode) *jsonNode {
+	node := &jsonNode{Kind: kind, Range: toJSONRange(n)}
+	for _, child := range children {
+		if child != nil {
+			node.Children = append(node.Children, child)
+		}
+	}
+
+	return node
+}
+
+func (node *jsonNode) as(role string) *jsonNode {
+	if node != nil {
+		node.Role = role
+	}
+	return node
+}
+
+func (node *jsonNode) with(value string) *jsonNode {
+	node.Value = &value
+	return node
+}
+
+// declare adds names to scope, numbering them from the next free index
+// of their kind.
+func declare(scope map[string]*jsonSymbol, kind string, t *jack_ast.TypeName, names []*jack_ast.Ident) {
+	index := 0
+	for _, sym := range scope {
+		if sym.Kind == kind {
+			index++
+		}
+	}
+
+	for _, name := range names {
+		scope[name.Name] = &jsonSymbol{kind, t.Name, index}
+		index++
+	}
+}
+
+func (j *jsonWriter) lookup(name string) *jsonSymbol {
+	if sym, ok := j.local[name]; ok {
+		return sym
+	}
+	return j.class[name]
+}
+
+func (j *jsonWriter) ident(id *jack_ast.Ident) *jsonNode {
+	node := newJSONNode("Ident", id).with(id.Name)
+	node.Symbol = j.lookup(id.Name)
+
+	return node
+}
+
+func (j *jsonWriter) typeName(t *jack_ast.TypeName) *jsonNode {
+	return newJSONNode("TypeName", t).with(t.Name).as("type")
+}
+
+func (j *jsonWriter) names(node *jsonNode, names []*jack_ast.Ident) *jsonNode {
+	for _, name := range names {
+		node.Children = append(node.Children, j.ident(name).as("name"))
+	}
+	return node
+}
+
+func (j *jsonWriter) classNode(class *jack_ast.Class) *jsonNode {
+	node := newJSONNode("Class", class, newJSONNode("Ident", class.Name).with(class.Name.Name).as("name"))
+
+	for _, dec := range class.Decls() {
+		switch dec := dec.(type) {
+		case *jack_ast.ClassVarDec:
+			declare(j.class, dec.Keyword.Spelling(), dec.Type, dec.Names)
+			child := newJSONNode("ClassVarDec", dec, j.typeName(dec.Type)).with(dec.Keyword.Spelling()).as("var")
+			node.Children = append(node.Children, j.names(child, dec.Names))
+		case *jack_ast.ConstDec:
+			node.Children = append(node.Children, newJSONNode("ConstDec", dec,
+				j.typeName(dec.Type),
+				newJSONNode("Ident", dec.Name).with(dec.Name.Name).as("name"),
+				j.expr(dec.Value).as("value"),
+			).as("const"))
+		case *jack_ast.EnumDec:
+			child := newJSONNode("EnumDec", dec, newJSONNode("Ident", dec.Name).with(dec.Name.Name).as("name"))
+			for _, member := range dec.Members {
+				child.Children = append(child.Children, newJSONNode("Ident", member).with(member.Name).as("member"))
+			}
+			node.Children = append(node.Children, child.as("enum"))
+		}
+	}
+
+	for _, dec := range class.Subroutines {
+		node.Children = append(node.Children, j.subroutine(dec).as("subroutine"))
+	}
+
+	return node
+}
+
+func (j *jsonWriter) subroutine(dec *jack_ast.SubroutineDec) *jsonNode {
+	j.local = map[string]*jsonSymbol{}
+	if dec.Keyword == jack_tokenizer.KW_METHOD {
+		// argument 0 of a method is this, which can never clash with a
+		// name since it is a keyword
+		j.local["this"] = &jsonSymbol{"argument", j.name, 0}
+	}
+
+	node := newJSONNode("SubroutineDec", dec,
+		j.typeName(dec.Return).as("return"),
+		newJSONNode("Ident", dec.Name).with(dec.Name.Name).as("name"),
+	).with(dec.Keyword.Spelling())
+
+	for _, param := range dec.Params {
+		declare(j.local, "argument", param.Type, []*jack_ast.Ident{param.Name})
+		node.Children = append(node.Children, newJSONNode("Param", param, j.typeName(param.Type), j.ident(param.Name).as("name")).as("param"))
+	}
+
+	body := newJSONNode("SubroutineBody", dec.Body)
+	for _, v := range dec.Body.Vars {
+		declare(j.local, "local", v.Type, v.Names)
+		child := newJSONNode("VarDec", v, j.typeName(v.Type)).as("var")
+		body.Children = append(body.Children, j.names(child, v.Names))
+	}
+	for _, stmt := range dec.Body.Stmts {
+		body.Children = append(body.Children, j.stmt(stmt).as("stmt"))
+	}
+	node.Children = append(node.Children, body.as("body"))
+
+	j.local = nil
+	return node
+}
+
+func (j *jsonWriter) block(block *jack_ast.Block) *jsonNode {
+	node := newJSONNode("Block", block)
+	for _, stmt := range block.Stmts {
+		node.Children = append(node.Children, j.stmt(stmt).as("stmt"))
+	}
+	return node
+}
+
+func (j *jsonWriter) stmt(stmt jack_ast.Stmt) *jsonNode {
+	switch stmt := stmt.(type) {
+	case *jack_ast.Block:
+		return j.block(stmt)
+	case *jack_ast.LetStmt:
+		node := newJSONNode("LetStmt", stmt,
+			j.ident(stmt.Name).as("name"),
+			j.expr(stmt.Index).as("index"),
+			j.expr(stmt.Value).as("value"),
+		)
+		if _, ok := stmt.Assign.AssignOp(); ok {
+			node.with(stmt.Assign.Spelling())
+		}
+		return node
+	case *jack_ast.IfStmt:
+		return newJSONNode("IfStmt", stmt,
+			j.expr(stmt.Cond).as("cond"),
+			j.block(stmt.Body).as("body"),
+			j.stmt(stmt.Else).as("else"),
+		)
+	case *jack_ast.WhileStmt:
+		return newJSONNode("WhileStmt", stmt,
+			j.expr(stmt.Cond).as("cond"),
+			j.block(stmt.Body).as("body"),
+		)
+	case *jack_ast.DoStmt:
+		return newJSONNode("DoStmt", stmt, j.expr(stmt.Call).as("call"))
+	case *jack_ast.ReturnStmt:
+		return newJSONNode("ReturnStmt", stmt, j.expr(stmt.Value).as("value"))
+	case *jack_ast.ForStmt:
+		return newJSONNode("ForStmt", stmt,
+			j.stmt(stmt.Init).as("init"),
+			j.expr(stmt.Cond).as("cond"),
+			j.stmt(stmt.Post).as("post"),
+			j.block(stmt.Body).as("body"),
+		)
+	case *jack_ast.BreakStmt:
+		return newJSONNode("BreakStmt", stmt)
+	case *jack_ast.ContinueStmt:
+		return newJSONNode("ContinueStmt", stmt)
+	case *jack_ast.BadStmt:
+		return newJSONNode("BadStmt", stmt)
+	}
+
+	return nil
+}
+
+func (j *jsonWriter) expr(expr jack_ast.Expr) *jsonNode {
+	switch expr := expr.(type) {
+	case *jack_ast.Ident:
+		return j.ident(expr)
+	case *jack_ast.IntLit:
+		return newJSONNode("IntLit", expr).with(expr.Value)
+	case *jack_ast.StringLit:
+		return newJSONNode("StringLit", expr).with(expr.Value)
+	case *jack_ast.KeywordLit:
+		node := newJSONNode("KeywordLit", expr).with(expr.Keyword.Spelling())
+		if expr.Keyword == jack_tokenizer.KW_THIS {
+			node.Symbol = j.lookup("this")
+		}
+		return node
+	case *jack_ast.ParenExpr:
+		return newJSONNode("ParenExpr", expr, j.expr(expr.X).as("x"))
+	case *jack_ast.UnaryExpr:
+		return newJSONNode("UnaryExpr", expr, j.expr(expr.X).as("x")).with(expr.Op.Spelling())
+	case *jack_ast.BinaryExpr:
+		return newJSONNode("BinaryExpr", expr, j.expr(expr.X).as("x"), j.expr(expr.Y).as("y")).with(expr.Op.Spelling())
+	case *jack_ast.IndexExpr:
+		return newJSONNode("IndexExpr", expr, j.ident(expr.X).as("x"), j.expr(expr.Index).as("index"))
+	case *jack_ast.CallExpr:
+		node := newJSONNode("CallExpr", expr)
+		if expr.Receiver != nil {
+			// a receiver that is not a variable names a class
+			node.Children = append(node.Children, j.ident(expr.Receiver).as("receiver"))
+		}
+		node.Children = append(node.Children, newJSONNode("Ident", expr.Name).with(expr.Name.Name).as("name"))
+		for _, arg := range expr.Args {
+			node.Children = append(node.Children, j.expr(arg).as("arg"))
+		}
+		return node
+	case *jack_ast.SelectorExpr:
+		return newJSONNode("SelectorExpr", expr,
+			newJSONNode("Ident", expr.X).with(expr.X.Name).as("x"),
+			newJSONNode("Ident", expr.Sel).with(expr.Sel.Name).as("name"),
+		)
+	case *jack_ast.BadExpr:
+		return newJSONNode("BadExpr", expr)
+	}
+
+	return nil
+}
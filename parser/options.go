@@ -0,0 +1,50 @@
+package jack_parser
+
+type options struct {
+	precedence bool
+	statements bool
+	compound   bool
+	constants  bool
+}
+
+// An Option configures which dialect of Jack is parsed.
+type Option func(*options)
+
+// WithPrecedence parses expressions with conventional operator
+// precedence instead of strictly left to right. From tightest to
+// loosest: '*' and '/', then '+' and '-', then '<', '>' and '=', then
+// '&', then '|'. Operators of the same precedence group left to right.
+func WithPrecedence() Option {
+	return func(o *options) {
+		o.precedence = true
+	}
+}
+
+// WithExtendedStatements accepts else if chains, C-style for loops, and
+// break and continue. The lexer must be run WithExtendedStatements as
+// well, to make for, break and continue keywords.
+func WithExtendedStatements() Option {
+	return func(o *options) {
+		o.statements = true
+	}
+}
+
+// WithCompoundAssignment accepts let statements assigning with +=, -=,
+// *=, /=, &= or |=, which apply their operator to the variable or array
+// element and the value. The lexer must be run WithCompoundAssignment
+// as well, to make these single symbols.
+func WithCompoundAssignment() Option {
+	return func(o *options) {
+		o.compound = true
+	}
+}
+
+// WithConstants accepts class level constant declarations, such as
+// const int MAX = 512;, and enums, such as enum Dir { UP, DOWN }, along
+// with the terms Game.MAX and Dir.UP naming them. The lexer must be run
+// WithConstants as well, to make const and enum keywords.
+func WithConstants() Option {
+	return func(o *options) {
+		o.constants = true
+	}
+}
@@ -1,11 +1,9 @@
 package jack_parser
 
 import (
-	"errors"
-	"fmt"
 	"io"
-	"strings"
 
+	jack_ast "github.com/renojcpp/n2t-compiler/ast"
 	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
 )
 
@@ -21,34 +19,117 @@ var typePair = []tokenpair{
 	{jack_tokenizer.IDENTIFIER, jack_tokenizer.NONE},
 }
 
+var statementPairs = []tokenpair{
+	{jack_tokenizer.KEYWORD, jack_tokenizer.KW_LET},
+	{jack_tokenizer.KEYWORD, jack_tokenizer.KW_IF},
+	{jack_tokenizer.KEYWORD, jack_tokenizer.KW_WHILE},
+	{jack_tokenizer.KEYWORD, jack_tokenizer.KW_DO},
+	{jack_tokenizer.KEYWORD, jack_tokenizer.KW_RETURN},
+	{jack_tokenizer.KEYWORD, jack_tokenizer.KW_FOR},
+	{jack_tokenizer.KEYWORD, jack_tokenizer.KW_BREAK},
+	{jack_tokenizer.KEYWORD, jack_tokenizer.KW_CONTINUE},
+}
+
+// extendedWords are the keywords of the extended statements, which
+// are plain identifiers to a lexer without WithExtendedStatements. They
+// are recognized anyway where a statement starts, so as to say what is
+// wrong, paired with the token that has to follow them there.
+var extendedWords = map[string]struct {
+	st   jack_tokenizer.TokenSubtype
+	next jack_tokenizer.TokenSubtype
+}{
+	"for":      {jack_tokenizer.KW_FOR, jack_tokenizer.SYM_LEFT_PAREN},
+	"break":    {jack_tokenizer.KW_BREAK, jack_tokenizer.SYM_SEMICOLON},
+	"continue": {jack_tokenizer.KW_CONTINUE, jack_tokenizer.SYM_SEMICOLON},
+}
+
+// constantWords are the keywords of the constants dialect, which are
+// plain identifiers to a lexer without WithConstants.
+var constantWords = map[string]jack_tokenizer.TokenSubtype{
+	"const": jack_tokenizer.KW_CONST,
+	"enum":  jack_tokenizer.KW_ENUM,
+}
+
+// splitAssign reports whether st followed by '=' spells a compound
+// assignment.
+func splitAssign(st jack_tokenizer.TokenSubtype) bool {
+	switch st {
+	case jack_tokenizer.SYM_PLUS, jack_tokenizer.SYM_MINUS, jack_tokenizer.SYM_ASTERISK,
+		jack_tokenizer.SYM_SLASH, jack_tokenizer.SYM_AMPERSAND, jack_tokenizer.SYM_PIPE:
+		return true
+	}
+	return false
+}
+
+// precedence ranks the binary operators from loosest to tightest for
+// WithPrecedence.
+var precedence = map[jack_tokenizer.TokenSubtype]int{
+	jack_tokenizer.SYM_PIPE:         1,
+	jack_tokenizer.SYM_AMPERSAND:    2,
+	jack_tokenizer.SYM_LESS_THAN:    3,
+	jack_tokenizer.SYM_GREATER_THAN: 3,
+	jack_tokenizer.SYM_EQUALS:       3,
+	jack_tokenizer.SYM_PLUS:         4,
+	jack_tokenizer.SYM_MINUS:        4,
+	jack_tokenizer.SYM_ASTERISK:     5,
+	jack_tokenizer.SYM_SLASH:        5,
+}
+
+var opPairs = []tokenpair{
+	{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_PLUS},
+	{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_MINUS},
+	{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_ASTERISK},
+	{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_SLASH},
+	{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_AMPERSAND},
+	{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_PIPE},
+	{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_LESS_THAN},
+	{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_GREATER_THAN},
+	{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_EQUALS},
+}
+
+// parser builds the syntax tree of a class by recursive descent, one
+// method per production of the Jack grammar.
+//
+// A syntax error bails out of the production in progress to the
+// statement or declaration around it, which skips ahead to a token it can
+// carry on from. Every error in the file is collected along the way.
 type parser struct {
-	tokens []jack_tokenizer.Token
-	writer io.Writer
-	index  int
-	err    error
+	tokens  jack_tokenizer.TokenStream
+	current jack_tokenizer.Token
+	diags   jack_tokenizer.Diagnostics
+	err     error // read error
+	opts    options
+	loops   int  // how many loops the statement being parsed is in
+	skipped bool // recovery skipped to the end of the file
 }
 
-func NewParser(tokens []jack_tokenizer.Token, w io.Writer) *parser {
-	return &parser{
+func NewParser(tokens jack_tokenizer.TokenStream, opts ...Option) *parser {
+	p := &parser{
 		tokens,
-		w,
-		0,
+		jack_tokenizer.Token{},
+		nil,
 		nil,
+		options{},
+		0,
+		false,
 	}
-}
+	for _, opt := range opts {
+		opt(&p.opts)
+	}
+	p.Advance()
 
-func (s *parser) process(pairs []tokenpair) {
-	if s.matches(pairs) {
-		io.WriteString(s.writer, (s.Current().Lexeme))
-	} else {
-		var ss strings.Builder
-		for _, p := range pairs {
-			ss.WriteString(fmt.Sprintf("{ %s, %s }", p.tt.String(), p.st.String()))
-		}
+	return p
+}
 
-		s.err = fmt.Errorf("%s %s %s %s: grammar error: got %s, wanted %s @ %d", s.tokens[s.index-2].Lexeme, s.tokens[s.index-1].Lexeme, s.tokens[s.index].Lexeme, s.tokens[s.index+1].Lexeme, s.Current().Lexeme, ss.String(), s.index)
+// process consumes the current token, which must be one of pairs.
+func (s *parser) process(pairs []tokenpair) *jack_tokenizer.Token {
+	if !s.matches(pairs) {
+		s.expected(describe(pairs))
 	}
+	ret := s.Current()
 	s.Advance()
+
+	return ret
 }
 
 func (s *parser) matches(pairs []tokenpair) bool {
@@ -62,162 +143,240 @@ func (s *parser) matches(pairs []tokenpair) bool {
 	return false
 }
 
-func (s *parser) atEnd() bool {
-	return s.index >= len(s.tokens)
+// peek returns the token after the current one. One token of
+// lookahead past Current is all the grammar ever needs.
+func (s *parser) peek() jack_tokenizer.Token {
+	token, _ := s.tokens.Peek(0)
+	return token
 }
 
-func (s *parser) peek() (*jack_tokenizer.Token, error) {
-	if s.atEnd() || s.index+1 >= len(s.tokens) {
-		return nil, errors.New("error at end")
-	}
-
-	return &s.tokens[s.index+1], nil
+// Current returns a copy of the token being looked at, so callers may
+// hold on to it across calls to Advance.
+func (s *parser) Current() *jack_tokenizer.Token {
+	token := s.current
+	return &token
 }
 
-func (s *parser) Current() *jack_tokenizer.Token {
-	return &s.tokens[s.index]
+func (s *parser) atEnd() bool {
+	return s.current.Tokentype == jack_tokenizer.EOF
 }
 
 func (s *parser) Advance() {
-	s.index++
+	token, err := s.tokens.Next()
+	switch err := err.(type) {
+	case nil:
+	case jack_tokenizer.Diagnostic:
+		s.diags = append(s.diags, err)
+	case jack_tokenizer.Diagnostics:
+		s.diags = append(s.diags, err...)
+	default:
+		s.err = err
+	}
+	s.current = token
 }
 
-func (s *parser) Parse() error {
-	s.Class()
+// Parse parses a whole class. The tree is returned even when there
+// were errors, with BadStmt nodes for the statements that could not be
+// made sense of; the error is then a Diagnostics listing every lexical
+// and syntax error in the file.
+func (s *parser) Parse() (*jack_ast.Class, error) {
+	class := s.Class()
+	if s.err != nil {
+		return class, s.err
+	}
+
+	return class, s.diagnostics().Err()
+}
 
-	return s.err
+// Diagnostics returns every problem found while parsing, warnings
+// included, in source order.
+func (s *parser) Diagnostics() jack_tokenizer.Diagnostics {
+	return s.diagnostics()
 }
 
-func (s *parser) helper_type(additional []tokenpair) {
+func (s *parser) helper_type(additional []tokenpair) *jack_ast.TypeName {
 	tp := make([]tokenpair, 0)
 	tp = append(tp, typePair...)
 	tp = append(tp, additional...)
-	s.process(tp)
-}
 
-func (s *parser) helper_typeVarName() {
-	s.process(typePair)
-	s.process([]tokenpair{
-		{jack_tokenizer.IDENTIFIER, jack_tokenizer.NONE},
-	})
-}
+	if !s.matches(tp) {
+		s.expected("type")
+	}
+	token := s.process(tp)
+	typ := &jack_ast.TypeName{NamePos: token.Pos, Name: token.Lexeme, Keyword: jack_tokenizer.NONE}
+	if token.Tokentype == jack_tokenizer.KEYWORD {
+		typ.Keyword = token.Subtype
+	}
 
-func (s *parser) wrap(tag string, f func()) {
-	io.WriteString(s.writer, "<"+tag+">")
-	f()
-	io.WriteString(s.writer, "</"+tag+">")
+	return typ
 }
 
-func (s *parser) symbolHelper(st jack_tokenizer.TokenSubtype) {
-	io.WriteString(s.writer, "<symbol>")
-	s.process([]tokenpair{
+func (s *parser) symbolHelper(st jack_tokenizer.TokenSubtype) jack_tokenizer.Position {
+	return s.process([]tokenpair{
 		{jack_tokenizer.SYMBOL, st},
-	})
-	io.WriteString(s.writer, "</symbol>")
+	}).Pos
 }
 
-func (s *parser) identifierHelper() {
-	io.WriteString(s.writer, "<identifier>")
-	s.process([]tokenpair{
+func (s *parser) identifierHelper() *jack_ast.Ident {
+	token := s.process([]tokenpair{
 		{jack_tokenizer.IDENTIFIER, jack_tokenizer.NONE},
 	})
-	io.WriteString(s.writer, "</identifier>")
+
+	return &jack_ast.Ident{NamePos: token.Pos, Name: token.Lexeme}
 }
 
-func (s *parser) keywordHelper(st jack_tokenizer.TokenSubtype) {
-	io.WriteString(s.writer, "<keyword>")
-	s.process([]tokenpair{
+func (s *parser) keywordHelper(st jack_tokenizer.TokenSubtype) jack_tokenizer.Position {
+	return s.process([]tokenpair{
 		{jack_tokenizer.KEYWORD, st},
-	})
-	io.WriteString(s.writer, "</keyword>")
+	}).Pos
 }
 
-// compiles a Class
-func (s *parser) Class() {
-	io.WriteString(s.writer, "<class>")
+// identifierList parses varName (',' varName)*.
+func (s *parser) identifierList() []*jack_ast.Ident {
+	names := []*jack_ast.Ident{s.identifierHelper()}
+	for s.matches([]tokenpair{{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_COMMA}}) {
+		s.symbolHelper(jack_tokenizer.SYM_COMMA)
+		names = append(names, s.identifierHelper())
+	}
 
-	s.keywordHelper(jack_tokenizer.KW_CLASS)
-	s.identifierHelper()
-	s.symbolHelper(jack_tokenizer.SYM_LEFT_BRACE)
+	return names
+}
 
-	for s.matches([]tokenpair{
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_STATIC},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_FIELD},
-	}) {
-		s.ClassVarDec()
+// compiles a Class
+func (s *parser) Class() *jack_ast.Class {
+	class := &jack_ast.Class{}
+	s.recovering(func() {
+		class.Class = s.keywordHelper(jack_tokenizer.KW_CLASS)
+		class.Name = s.identifierHelper()
+		class.Lbrace = s.symbolHelper(jack_tokenizer.SYM_LEFT_BRACE)
+	}, s.syncDeclaration)
+	if class.Name == nil {
+		class.Name = &jack_ast.Ident{NamePos: class.Class}
 	}
 
-	for s.matches([]tokenpair{
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_CONSTRUCTOR},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_METHOD},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_FUNCTION},
-	}) {
-		s.Subroutine()
+	for !s.atEnd() && !s.matches([]tokenpair{{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_RIGHT_BRACE}}) {
+		s.recovering(func() {
+			if word, ok := constantWords[s.current.Lexeme]; ok && s.current.Tokentype == jack_tokenizer.IDENTIFIER {
+				// nothing else starts with an identifier here, so parse
+				// it as the keyword it was meant to be
+				s.current.Tokentype, s.current.Subtype = jack_tokenizer.KEYWORD, word
+			}
+
+			switch {
+			case s.matches([]tokenpair{
+				{jack_tokenizer.KEYWORD, jack_tokenizer.KW_STATIC},
+				{jack_tokenizer.KEYWORD, jack_tokenizer.KW_FIELD},
+				{jack_tokenizer.KEYWORD, jack_tokenizer.KW_CONST},
+				{jack_tokenizer.KEYWORD, jack_tokenizer.KW_ENUM},
+			}):
+				if len(class.Subroutines) > 0 {
+					s.errorf(s.Current().Pos, CodeUnexpectedToken, "expected subroutine declaration, found %s", found(s.Current()))
+				}
+				switch st := s.Current().Subtype; st {
+				case jack_tokenizer.KW_CONST, jack_tokenizer.KW_ENUM:
+					if !s.opts.constants {
+						s.notInDialect(s.Current().Pos, "'"+st.Spelling()+"' is", "constants")
+					}
+					if st == jack_tokenizer.KW_CONST {
+						class.Consts = append(class.Consts, s.ConstDec())
+					} else {
+						class.Enums = append(class.Enums, s.EnumDec())
+					}
+				default:
+					class.Vars = append(class.Vars, s.ClassVarDec())
+				}
+			default:
+				class.Subroutines = append(class.Subroutines, s.Subroutine())
+			}
+		}, s.syncDeclaration)
 	}
 
-	s.symbolHelper(jack_tokenizer.SYM_RIGHT_BRACE)
-	io.WriteString(s.writer, "</class>")
+	s.recovering(func() {
+		class.Rbrace = s.symbolHelper(jack_tokenizer.SYM_RIGHT_BRACE)
+		if !s.atEnd() {
+			s.errorf(s.Current().Pos, CodeUnexpectedToken, "expected end of file, found %s", found(s.Current()))
+		}
+	}, func() {})
+
+	return class
 }
 
 // Compiles a static variable declaration or a field declaration
-func (s *parser) ClassVarDec() {
-	io.WriteString(s.writer, "<classVarDec>")
-
-	s.wrap("keyword", func() {
-		s.process([]tokenpair{
-			{jack_tokenizer.KEYWORD, jack_tokenizer.KW_STATIC},
-			{jack_tokenizer.KEYWORD, jack_tokenizer.KW_FIELD},
-		})
+func (s *parser) ClassVarDec() *jack_ast.ClassVarDec {
+	token := s.process([]tokenpair{
+		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_STATIC},
+		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_FIELD},
 	})
 
-	s.helper_typeVarName()
-	// (',' varName)
-	for s.matches([]tokenpair{{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_COMMA}}) {
-		s.symbolHelper(jack_tokenizer.SYM_COMMA)
-		s.identifierHelper()
+	return &jack_ast.ClassVarDec{
+		KeywordPos: token.Pos,
+		Keyword:    token.Subtype,
+		Type:       s.helper_type(nil),
+		Names:      s.identifierList(),
+		Semicolon:  s.symbolHelper(jack_tokenizer.SYM_SEMICOLON),
 	}
-	s.symbolHelper(jack_tokenizer.SYM_SEMICOLON)
-	io.WriteString(s.writer, "</classVarDec>")
 }
 
-// Compiles a complete method, function or constructor
-func (s *parser) Subroutine() {
-	io.WriteString(s.writer, "<subroutineDec>")
-	s.wrap("keyword", func() {
-		s.process([]tokenpair{
-			{jack_tokenizer.KEYWORD, jack_tokenizer.KW_CONSTRUCTOR},
-			{jack_tokenizer.KEYWORD, jack_tokenizer.KW_FUNCTION},
-			{jack_tokenizer.KEYWORD, jack_tokenizer.KW_METHOD},
-		})
-	})
-
-	s.helper_type([]tokenpair{
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_VOID},
-	})
-
-	s.identifierHelper()
+// Compiles a constant declaration:
+// 'const' type name '=' expression ';'
+func (s *parser) ConstDec() *jack_ast.ConstDec {
+	dec := &jack_ast.ConstDec{}
+	dec.Const = s.keywordHelper(jack_tokenizer.KW_CONST)
+	dec.Type = s.helper_type(nil)
+	dec.Name = s.identifierHelper()
+	s.symbolHelper(jack_tokenizer.SYM_EQUALS)
+	dec.Value = s.Expression()
+	dec.Semicolon = s.symbolHelper(jack_tokenizer.SYM_SEMICOLON)
 
-	s.symbolHelper(jack_tokenizer.SYM_LEFT_PAREN)
+	return dec
+}
 
-	s.Parameters()
+// Compiles an enum declaration:
+// 'enum' name '{' name (',' name)* '}'
+func (s *parser) EnumDec() *jack_ast.EnumDec {
+	return &jack_ast.EnumDec{
+		Enum:    s.keywordHelper(jack_tokenizer.KW_ENUM),
+		Name:    s.identifierHelper(),
+		Lbrace:  s.symbolHelper(jack_tokenizer.SYM_LEFT_BRACE),
+		Members: s.identifierList(),
+		Rbrace:  s.symbolHelper(jack_tokenizer.SYM_RIGHT_BRACE),
+	}
+}
 
-	s.symbolHelper(jack_tokenizer.SYM_RIGHT_PAREN)
+// Compiles a complete method, function or constructor
+func (s *parser) Subroutine() *jack_ast.SubroutineDec {
+	token := s.process([]tokenpair{
+		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_CONSTRUCTOR},
+		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_FUNCTION},
+		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_METHOD},
+	})
 
-	s.SubroutineBody()
-	io.WriteString(s.writer, "</subroutineDec>")
+	return &jack_ast.SubroutineDec{
+		KeywordPos: token.Pos,
+		Keyword:    token.Subtype,
+		Return: s.helper_type([]tokenpair{
+			{jack_tokenizer.KEYWORD, jack_tokenizer.KW_VOID},
+		}),
+		Name:   s.identifierHelper(),
+		Lparen: s.symbolHelper(jack_tokenizer.SYM_LEFT_PAREN),
+		Params: s.Parameters(),
+		Rparen: s.symbolHelper(jack_tokenizer.SYM_RIGHT_PAREN),
+		Body:   s.SubroutineBody(),
+	}
 }
 
 // Compiles a (possibly empty) Parameters
 // list. Does not handle the enclosing
 // parantheses tokens (ands).
-func (s *parser) Parameters() {
+func (s *parser) Parameters() []*jack_ast.Param {
+	var params []*jack_ast.Param
 	processTypeVarName := func() {
-		s.process(typePair)
-		s.identifierHelper()
+		params = append(params, &jack_ast.Param{
+			Type: s.helper_type(nil),
+			Name: s.identifierHelper(),
+		})
 	}
-	io.WriteString(s.writer, "<parameters>")
 	if s.matches(typePair) {
-
 		processTypeVarName()
 
 		for s.matches([]tokenpair{
@@ -227,323 +386,466 @@ func (s *parser) Parameters() {
 			processTypeVarName()
 		}
 	}
-	io.WriteString(s.writer, "</parameters>")
+
+	return params
 }
 
 // Compiles a subroutine's body
-func (s *parser) SubroutineBody() {
-	io.WriteString(s.writer, "<subroutineBody>")
-	s.symbolHelper(jack_tokenizer.SYM_LEFT_BRACE)
+func (s *parser) SubroutineBody() *jack_ast.SubroutineBody {
+	body := &jack_ast.SubroutineBody{}
+	body.Lbrace = s.symbolHelper(jack_tokenizer.SYM_LEFT_BRACE)
 
 	for s.matches([]tokenpair{
 		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_VAR},
 	}) {
-		s.VarDec()
+		s.recovering(func() {
+			body.Vars = append(body.Vars, s.VarDec())
+		}, s.syncStatement)
 	}
 
-	s.Statements()
+	body.Stmts = s.Statements()
+	body.Rbrace = s.symbolHelper(jack_tokenizer.SYM_RIGHT_BRACE)
 
-	io.WriteString(s.writer, "</subroutineBody>")
+	return body
 }
 
 // Compiles a var declaration
-func (s *parser) VarDec() {
-	io.WriteString(s.writer, "<varDec>")
-	s.keywordHelper(jack_tokenizer.KW_VAR)
-
-	s.helper_typeVarName()
-
-	for s.matches([]tokenpair{
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_COMMA},
-	}) {
-		s.symbolHelper(jack_tokenizer.SYM_COMMA)
-		s.identifierHelper()
+func (s *parser) VarDec() *jack_ast.VarDec {
+	return &jack_ast.VarDec{
+		Var:       s.keywordHelper(jack_tokenizer.KW_VAR),
+		Type:      s.helper_type(nil),
+		Names:     s.identifierList(),
+		Semicolon: s.symbolHelper(jack_tokenizer.SYM_SEMICOLON),
 	}
-
-	s.symbolHelper(jack_tokenizer.SYM_SEMICOLON)
-
-	io.WriteString(s.writer, "</varDec>")
 }
 
 // Compiles a sequeneces of statemnents
 // Does not handle the enclosing curly
 // bracket tokens { and }.
-func (s *parser) Statements() {
-	states := []tokenpair{
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_LET},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_IF},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_WHILE},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_DO},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_RETURN},
-	}
-
-	if s.matches(states) {
-		io.WriteString(s.writer, "<statements>")
-		for s.matches(states) {
-			switch {
-			case s.matches([]tokenpair{states[0]}):
-				s.LetStatement()
-			case s.matches([]tokenpair{states[1]}):
-				s.IfStatement()
-			case s.matches([]tokenpair{states[2]}):
-				s.While()
-			case s.matches([]tokenpair{states[3]}):
-				s.Do()
-			case s.matches([]tokenpair{states[4]}):
-				s.ReturnStatement()
-			}
+// Stops at the '}', or at a class member declaration, which
+// means the '}' is missing.
+func (s *parser) Statements() []jack_ast.Stmt {
+	var stmts []jack_ast.Stmt
+	for !s.atEnd() && !s.matches(classDecPairs) &&
+		!s.matches([]tokenpair{{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_RIGHT_BRACE}}) {
+		from := s.Current().Pos
+		var stmt jack_ast.Stmt
+		ok := s.recovering(func() {
+			stmt = s.statement()
+		}, s.syncStatement)
+		if !ok {
+			stmt = &jack_ast.BadStmt{From: from, To: s.Current().Pos}
 		}
-		io.WriteString(s.writer, "</statements>")
-	} else {
-		s.err = errors.New("unexpected lexeme " + s.Current().Lexeme)
+		stmts = append(stmts, stmt)
+	}
+
+	return stmts
+}
+
+func (s *parser) statement() jack_ast.Stmt {
+	if word, ok := extendedWords[s.current.Lexeme]; ok && s.current.Tokentype == jack_tokenizer.IDENTIFIER {
+		if peek := s.peek(); peek.Tokentype == jack_tokenizer.SYMBOL && peek.Subtype == word.next {
+			// parse it as the keyword it was meant to be, which gets
+			// past it in one piece
+			s.current.Tokentype, s.current.Subtype = jack_tokenizer.KEYWORD, word.st
+		}
+	}
+	if !s.matches(statementPairs) {
+		s.expected("statement")
+	}
+
+	switch st := s.Current().Subtype; st {
+	case jack_tokenizer.KW_LET:
+		return s.LetStatement()
+	case jack_tokenizer.KW_IF:
+		return s.IfStatement()
+	case jack_tokenizer.KW_WHILE:
+		return s.While()
+	case jack_tokenizer.KW_DO:
+		return s.Do()
+	case jack_tokenizer.KW_FOR, jack_tokenizer.KW_BREAK, jack_tokenizer.KW_CONTINUE:
+		if !s.opts.statements {
+			s.notInDialect(s.Current().Pos, "'"+st.Spelling()+"' is", "extended statements")
+		}
+		switch st {
+		case jack_tokenizer.KW_FOR:
+			return s.ForStatement()
+		case jack_tokenizer.KW_BREAK:
+			return s.BreakStatement()
+		default:
+			return s.ContinueStatement()
+		}
+	default:
+		return s.ReturnStatement()
+	}
+}
+
+// block parses '{' statements '}'.
+func (s *parser) block() *jack_ast.Block {
+	return &jack_ast.Block{
+		Lbrace: s.symbolHelper(jack_tokenizer.SYM_LEFT_BRACE),
+		Stmts:  s.Statements(),
+		Rbrace: s.symbolHelper(jack_tokenizer.SYM_RIGHT_BRACE),
 	}
 }
 
 // Compiles a let statement.
-func (s *parser) LetStatement() {
-	io.WriteString(s.writer, "<letStatement>")
-	s.keywordHelper(jack_tokenizer.KW_LET)
-	s.identifierHelper()
+func (s *parser) LetStatement() *jack_ast.LetStmt {
+	stmt := s.letClause()
+	stmt.Semicolon = s.symbolHelper(jack_tokenizer.SYM_SEMICOLON)
+
+	return stmt
+}
+
+// letClause parses a let statement up to its semicolon.
+func (s *parser) letClause() *jack_ast.LetStmt {
+	stmt := &jack_ast.LetStmt{}
+	stmt.Let = s.keywordHelper(jack_tokenizer.KW_LET)
+	stmt.Name = s.identifierHelper()
 
 	if s.matches([]tokenpair{
 		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_LEFT_BRACK},
 	}) {
 		s.symbolHelper(jack_tokenizer.SYM_LEFT_BRACK)
-
-		s.Expression()
-
+		stmt.Index = s.Expression()
 		s.symbolHelper(jack_tokenizer.SYM_RIGHT_BRACK)
 	}
 
-	s.symbolHelper(jack_tokenizer.SYM_EQUALS)
+	stmt.AssignPos, stmt.Assign = s.Current().Pos, s.Current().Subtype
+	if _, ok := stmt.Assign.AssignOp(); ok && s.Current().Tokentype == jack_tokenizer.SYMBOL {
+		if !s.opts.compound {
+			s.notInDialect(stmt.AssignPos, "'"+stmt.Assign.Spelling()+"' is", "compound assignment")
+		}
+		s.Advance()
+	} else if split := s.peek(); s.Current().Tokentype == jack_tokenizer.SYMBOL && splitAssign(s.Current().Subtype) &&
+		split.Subtype == jack_tokenizer.SYM_EQUALS && split.Pos.Offset == s.Current().End.Offset {
+		// a lexer without WithCompoundAssignment splits the symbol in
+		// two; say what was meant and go on past both
+		s.notInDialect(stmt.AssignPos, "'"+s.Current().Raw+"=' is", "compound assignment")
+		stmt.Assign = jack_tokenizer.SYM_EQUALS
+		s.Advance()
+		s.Advance()
+	} else {
+		stmt.Assign = jack_tokenizer.SYM_EQUALS
+		s.symbolHelper(jack_tokenizer.SYM_EQUALS)
+	}
+	stmt.Value = s.Expression()
 
-	s.Expression()
-	s.symbolHelper(jack_tokenizer.SYM_SEMICOLON)
+	return stmt
+}
+
+// condition parses '(' expression ')'.
+func (s *parser) condition() jack_ast.Expr {
+	s.symbolHelper(jack_tokenizer.SYM_LEFT_PAREN)
+	cond := s.Expression()
+	s.symbolHelper(jack_tokenizer.SYM_RIGHT_PAREN)
 
-	io.WriteString(s.writer, "</letStatement>")
+	return cond
 }
 
 // Compiles an if statement
 // possibly with a trailing else clause
-func (s *parser) IfStatement() {
-	io.WriteString(s.writer, "<ifStatement>")
+func (s *parser) IfStatement() *jack_ast.IfStmt {
+	stmt := &jack_ast.IfStmt{}
+	stmt.If = s.keywordHelper(jack_tokenizer.KW_IF)
+	stmt.Cond = s.condition()
+	stmt.Body = s.block()
 
-	s.keywordHelper(jack_tokenizer.KW_IF)
+	if s.matches([]tokenpair{
+		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_ELSE},
+	}) {
+		stmt.ElsePos = s.keywordHelper(jack_tokenizer.KW_ELSE)
+		if s.matches([]tokenpair{{jack_tokenizer.KEYWORD, jack_tokenizer.KW_IF}}) {
+			if !s.opts.statements {
+				s.notInDialect(stmt.ElsePos, "'else if' is", "extended statements")
+			}
+			stmt.Else = s.IfStatement()
+		} else {
+			stmt.Else = s.block()
+		}
+	}
 
-	s.symbolHelper(jack_tokenizer.SYM_LEFT_PAREN)
+	return stmt
+}
 
-	s.Expression()
+// Compiles a While statement
+func (s *parser) While() *jack_ast.WhileStmt {
+	stmt := &jack_ast.WhileStmt{
+		While: s.keywordHelper(jack_tokenizer.KW_WHILE),
+		Cond:  s.condition(),
+	}
+	stmt.Body = s.loopBody()
 
-	s.symbolHelper(jack_tokenizer.SYM_RIGHT_PAREN)
+	return stmt
+}
 
-	s.symbolHelper(jack_tokenizer.SYM_LEFT_BRACE)
+// loopBody parses the block of a loop, where break and continue may
+// appear.
+func (s *parser) loopBody() *jack_ast.Block {
+	s.loops++
+	defer func() { s.loops-- }()
 
-	s.Statements()
+	return s.block()
+}
 
-	s.symbolHelper(jack_tokenizer.SYM_RIGHT_BRACE)
+// forClause parses the let or do statement, without its semicolon, in
+// the header of a for loop, if there is one.
+func (s *parser) forClause() jack_ast.Stmt {
+	switch {
+	case s.matches([]tokenpair{{jack_tokenizer.KEYWORD, jack_tokenizer.KW_LET}}):
+		return s.letClause()
+	case s.matches([]tokenpair{{jack_tokenizer.KEYWORD, jack_tokenizer.KW_DO}}):
+		return &jack_ast.DoStmt{
+			Do:   s.keywordHelper(jack_tokenizer.KW_DO),
+			Call: s.SubroutineCall(),
+		}
+	}
 
-	if s.matches([]tokenpair{
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_ELSE},
-	}) {
+	return nil
+}
 
-		s.symbolHelper(jack_tokenizer.SYM_LEFT_BRACE)
+// Compiles a for statement:
+// 'for' '(' init? ';' expression? ';' post? ')' '{' statements '}'
+// where init and post are let or do statements.
+func (s *parser) ForStatement() *jack_ast.ForStmt {
+	stmt := &jack_ast.ForStmt{}
+	stmt.For = s.keywordHelper(jack_tokenizer.KW_FOR)
+	stmt.Lparen = s.symbolHelper(jack_tokenizer.SYM_LEFT_PAREN)
 
-		s.Statements()
+	if init := s.forClause(); init != nil {
+		stmt.Init = init
+		switch init := init.(type) {
+		case *jack_ast.LetStmt:
+			init.Semicolon = s.symbolHelper(jack_tokenizer.SYM_SEMICOLON)
+		case *jack_ast.DoStmt:
+			init.Semicolon = s.symbolHelper(jack_tokenizer.SYM_SEMICOLON)
+		}
+	} else {
+		s.symbolHelper(jack_tokenizer.SYM_SEMICOLON)
+	}
 
-		s.symbolHelper(jack_tokenizer.SYM_RIGHT_BRACE)
+	if !s.matches([]tokenpair{{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_SEMICOLON}}) {
+		stmt.Cond = s.Expression()
 	}
-	io.WriteString(s.writer, "</ifStatement>")
+	s.symbolHelper(jack_tokenizer.SYM_SEMICOLON)
+
+	stmt.Post = s.forClause()
+	stmt.Rparen = s.symbolHelper(jack_tokenizer.SYM_RIGHT_PAREN)
+	stmt.Body = s.loopBody()
+
+	return stmt
 }
 
-// Compiles a While statement
-func (s *parser) While() {
-	io.WriteString(s.writer, "<whileStatement>")
-	s.keywordHelper(jack_tokenizer.KW_WHILE)
-	s.symbolHelper(jack_tokenizer.SYM_LEFT_PAREN)
-	s.Expression()
-	s.symbolHelper(jack_tokenizer.SYM_RIGHT_PAREN)
-	s.symbolHelper(jack_tokenizer.SYM_LEFT_BRACE)
-	s.Statements()
-	s.symbolHelper(jack_tokenizer.SYM_RIGHT_BRACE)
-	io.WriteString(s.writer, "</whileStatement>")
+// Compiles a break statement, which leaves the innermost loop.
+func (s *parser) BreakStatement() *jack_ast.BreakStmt {
+	stmt := &jack_ast.BreakStmt{
+		Break:     s.keywordHelper(jack_tokenizer.KW_BREAK),
+		Semicolon: s.symbolHelper(jack_tokenizer.SYM_SEMICOLON),
+	}
+	if s.loops == 0 {
+		s.errorf(stmt.Break, CodeOutsideLoop, "break is not in a loop")
+	}
+
+	return stmt
+}
+
+// Compiles a continue statement, which starts the next pass of the
+// innermost loop.
+func (s *parser) ContinueStatement() *jack_ast.ContinueStmt {
+	stmt := &jack_ast.ContinueStmt{
+		Continue:  s.keywordHelper(jack_tokenizer.KW_CONTINUE),
+		Semicolon: s.symbolHelper(jack_tokenizer.SYM_SEMICOLON),
+	}
+	if s.loops == 0 {
+		s.errorf(stmt.Continue, CodeOutsideLoop, "continue is not in a loop")
+	}
+
+	return stmt
 }
 
 // Compiles a Do statement
-func (s *parser) Do() {
-	io.WriteString(s.writer, "<doStatement>")
-	s.keywordHelper(jack_tokenizer.KW_DO)
-	s.SubroutineCall()
-	s.symbolHelper(jack_tokenizer.SYM_SEMICOLON)
-	io.WriteString(s.writer, "</doStatement>")
+func (s *parser) Do() *jack_ast.DoStmt {
+	return &jack_ast.DoStmt{
+		Do:        s.keywordHelper(jack_tokenizer.KW_DO),
+		Call:      s.SubroutineCall(),
+		Semicolon: s.symbolHelper(jack_tokenizer.SYM_SEMICOLON),
+	}
 }
 
 // Compiles a return statement
-func (s *parser) ReturnStatement() {
-	begTerm := []tokenpair{
-		{jack_tokenizer.STRING_CONSTANT, jack_tokenizer.NONE},
-		{jack_tokenizer.INT_CONSTANT, jack_tokenizer.NONE},
-		{jack_tokenizer.IDENTIFIER, jack_tokenizer.NONE},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_PLUS},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_LEFT_PAREN},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_MINUS},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_TILDE},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_TRUE},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_FALSE},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_NULL},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_THIS},
+func (s *parser) ReturnStatement() *jack_ast.ReturnStmt {
+	stmt := &jack_ast.ReturnStmt{}
+	stmt.Return = s.keywordHelper(jack_tokenizer.KW_RETURN)
+	if !s.matches([]tokenpair{{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_SEMICOLON}}) {
+		stmt.Value = s.Expression()
 	}
+	stmt.Semicolon = s.symbolHelper(jack_tokenizer.SYM_SEMICOLON)
 
-	io.WriteString(s.writer, "<returnStatement>")
-	s.keywordHelper(jack_tokenizer.KW_RETURN)
-	if s.matches(begTerm) {
-		s.Expression()
-	}
-	s.symbolHelper(jack_tokenizer.SYM_SEMICOLON)
-	io.WriteString(s.writer, "</returnStatement>")
+	return stmt
 }
 
-// Compiles an Expression
-func (s *parser) Expression() {
-	op := []tokenpair{
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_PLUS},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_MINUS},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_ASTERISK},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_SLASH},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_ASTERISK},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_PIPE},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_LESS_THAN},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_GREATER_THAN},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_EQUALS},
+// Jack applies the operators of an expression strictly left to right,
+// so 1 + 2 * 3 is 9. WithPrecedence parses by the conventional rules
+// instead, and without it the parser warns where they would give a
+// different result.
+func (s *parser) Expression() jack_ast.Expr {
+	if s.opts.precedence {
+		return s.binaryExpr(1)
 	}
-	io.WriteString(s.writer, "<expression>")
-	s.Term()
-	for s.matches(op) {
-		s.process(op)
-		s.Term()
+
+	x := s.Term()
+	var prev *jack_tokenizer.Token
+	warned := false
+	for s.matches(opPairs) {
+		op := s.process(opPairs)
+		if prev != nil && !warned && precedence[op.Subtype] > precedence[prev.Subtype] {
+			s.warnf(op.Pos, CodePrecedence,
+				"'%s' is applied after the '%s' before it, since Jack evaluates operators left to right; add parentheses to make the order explicit",
+				op.Raw, prev.Raw)
+			warned = true
+		}
+		prev = op
+		x = &jack_ast.BinaryExpr{X: x, OpPos: op.Pos, Op: op.Subtype, Y: s.Term()}
 	}
-	io.WriteString(s.writer, "</expression>")
+
+	return x
+}
+
+// binaryExpr parses an expression whose operators all bind at least as
+// tightly as level, by precedence climbing.
+func (s *parser) binaryExpr(level int) jack_ast.Expr {
+	x := s.Term()
+	for s.matches(opPairs) && precedence[s.Current().Subtype] >= level {
+		op := s.process(opPairs)
+		y := s.binaryExpr(precedence[op.Subtype] + 1)
+		x = &jack_ast.BinaryExpr{X: x, OpPos: op.Pos, Op: op.Subtype, Y: y}
+	}
+
+	return x
 }
 
 // Compiles a Term. If the current token is an
 // identifier, the routine must resolve it
 // into a variable, an array element, or a
-// subroutine call. A single lookahead tokezn,
+// subroutine call. A single lookahead token,
 // which may be [, (, or ., suffices to distinguish
 // between the possibilities.
 // Any other token is not part of this Term
 // and should not be advanced over.
-func (s *parser) Term() {
-	io.WriteString(s.writer, "<term>")
-	switch s.Current().Tokentype {
+func (s *parser) Term() jack_ast.Expr {
+	token := s.Current()
+	switch token.Tokentype {
 	case jack_tokenizer.IDENTIFIER:
 		// variable, array element or subroutine
-		peek, err := s.peek()
-		if err != nil {
-			s.err = nil
-		} else {
-			switch peek.Subtype {
-			case jack_tokenizer.SYM_LEFT_PAREN:
-			case jack_tokenizer.SYM_PERIOD:
-				s.SubroutineCall()
-			case jack_tokenizer.SYM_LEFT_BRACK:
-				// varname[expression]
-				s.identifierHelper()
-				s.symbolHelper(jack_tokenizer.SYM_LEFT_BRACK)
-				s.Expression()
-				s.symbolHelper(jack_tokenizer.SYM_RIGHT_BRACK)
-			default:
-				s.identifierHelper()
+		switch peek := s.peek(); {
+		case peek.Tokentype != jack_tokenizer.SYMBOL:
+		case peek.Subtype == jack_tokenizer.SYM_LEFT_PAREN:
+			return s.SubroutineCall()
+		case peek.Subtype == jack_tokenizer.SYM_PERIOD:
+			return s.qualified()
+		case peek.Subtype == jack_tokenizer.SYM_LEFT_BRACK:
+			// varname[expression]
+			return &jack_ast.IndexExpr{
+				X:      s.identifierHelper(),
+				Lbrack: s.symbolHelper(jack_tokenizer.SYM_LEFT_BRACK),
+				Index:  s.Expression(),
+				Rbrack: s.symbolHelper(jack_tokenizer.SYM_RIGHT_BRACK),
 			}
-
 		}
+		return s.identifierHelper()
 	case jack_tokenizer.INT_CONSTANT:
-		s.process([]tokenpair{
-			{jack_tokenizer.INT_CONSTANT, jack_tokenizer.NONE},
-		})
+		s.Advance()
+		return &jack_ast.IntLit{ValuePos: token.Pos, Value: token.Lexeme, Raw: token.Raw}
 	case jack_tokenizer.STRING_CONSTANT:
-		s.process([]tokenpair{
-			{jack_tokenizer.STRING_CONSTANT, jack_tokenizer.NONE},
-		})
+		s.Advance()
+		return &jack_ast.StringLit{ValuePos: token.Pos, Value: token.Lexeme, Raw: token.Raw}
 	case jack_tokenizer.SYMBOL:
-		switch s.Current().Subtype {
+		switch token.Subtype {
 		case jack_tokenizer.SYM_LEFT_PAREN:
-			s.symbolHelper(jack_tokenizer.SYM_LEFT_PAREN)
-			s.Expression()
-			s.symbolHelper(jack_tokenizer.SYM_RIGHT_PAREN)
-		case jack_tokenizer.SYM_MINUS:
-		case jack_tokenizer.SYM_TILDE:
-			s.symbolHelper(s.Current().Subtype)
-			s.Term()
+			return &jack_ast.ParenExpr{
+				Lparen: s.symbolHelper(jack_tokenizer.SYM_LEFT_PAREN),
+				X:      s.Expression(),
+				Rparen: s.symbolHelper(jack_tokenizer.SYM_RIGHT_PAREN),
+			}
+		case jack_tokenizer.SYM_MINUS, jack_tokenizer.SYM_TILDE:
+			s.Advance()
+			return &jack_ast.UnaryExpr{OpPos: token.Pos, Op: token.Subtype, X: s.Term()}
 		}
 	case jack_tokenizer.KEYWORD:
-		switch s.Current().Subtype {
-		case jack_tokenizer.KW_TRUE:
-		case jack_tokenizer.KW_FALSE:
-		case jack_tokenizer.KW_NULL:
-		case jack_tokenizer.KW_THIS:
-			s.keywordHelper(s.Current().Subtype)
+		switch token.Subtype {
+		case jack_tokenizer.KW_TRUE, jack_tokenizer.KW_FALSE, jack_tokenizer.KW_NULL, jack_tokenizer.KW_THIS:
+			s.Advance()
+			return &jack_ast.KeywordLit{ValuePos: token.Pos, Keyword: token.Subtype}
 		}
 	}
 
-	io.WriteString(s.writer, "</term>")
+	s.expected("expression")
+	return nil
 }
 
-func (s *parser) SubroutineCall() {
-	io.WriteString(s.writer, "<subroutineCall>")
-	s.identifierHelper()
+// Compiles a subroutine call, name(args) or receiver.name(args).
+func (s *parser) SubroutineCall() *jack_ast.CallExpr {
+	call := &jack_ast.CallExpr{}
+	call.Name = s.identifierHelper() // class's name or subroutine name, depending on if theres a .
 
-	t := s.Current()
-	s.process([]tokenpair{
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_LEFT_PAREN},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_PERIOD},
-	})
+	if s.matches([]tokenpair{{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_PERIOD}}) {
+		s.symbolHelper(jack_tokenizer.SYM_PERIOD)
+		call.Receiver = call.Name
+		call.Name = s.identifierHelper()
+	}
 
-	switch t.Subtype {
-	case jack_tokenizer.SYM_PERIOD:
-		s.identifierHelper()
-		s.symbolHelper(jack_tokenizer.SYM_LEFT_PAREN)
+	s.arguments(call)
+
+	return call
+}
 
-		fallthrough
-	case jack_tokenizer.SYM_LEFT_PAREN:
-		s.ExpressionList()
-		s.symbolHelper(jack_tokenizer.SYM_RIGHT_PAREN)
+// qualified parses a term starting with a name and a '.': a call
+// through a class or object, or with the constants dialect, a constant
+// Class.NAME or an enum member Enum.MEMBER.
+func (s *parser) qualified() jack_ast.Expr {
+	x := s.identifierHelper()
+	s.symbolHelper(jack_tokenizer.SYM_PERIOD)
+	name := s.identifierHelper()
+
+	if s.opts.constants && !s.matches([]tokenpair{{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_LEFT_PAREN}}) {
+		return &jack_ast.SelectorExpr{X: x, Sel: name}
 	}
-	io.WriteString(s.writer, "</subroutineCall>")
+
+	call := &jack_ast.CallExpr{Receiver: x, Name: name}
+	s.arguments(call)
+
+	return call
+}
+
+// arguments parses the parenthesized arguments of a call.
+func (s *parser) arguments(call *jack_ast.CallExpr) {
+	call.Lparen = s.symbolHelper(jack_tokenizer.SYM_LEFT_PAREN)
+	call.Args = s.ExpressionList()
+	call.Rparen = s.symbolHelper(jack_tokenizer.SYM_RIGHT_PAREN)
 }
 
 // Compiles a (possibly empty) comma-
-// separated list of expression. Returns
-// the number of expressions in the list
-func (s *parser) ExpressionList() int {
-	begTerm := []tokenpair{
-		{jack_tokenizer.STRING_CONSTANT, jack_tokenizer.NONE},
-		{jack_tokenizer.INT_CONSTANT, jack_tokenizer.NONE},
-		{jack_tokenizer.IDENTIFIER, jack_tokenizer.NONE},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_PLUS},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_LEFT_PAREN},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_MINUS},
-		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_TILDE},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_TRUE},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_FALSE},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_NULL},
-		{jack_tokenizer.KEYWORD, jack_tokenizer.KW_THIS},
-	}
-	count := 0
-	io.WriteString(s.writer, "<expressionList>")
-	if s.matches(begTerm) {
-		count++
-		s.Expression()
-		for s.matches([]tokenpair{
-			{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_COMMA},
-		}) {
-			count++
-			s.symbolHelper(jack_tokenizer.SYM_COMMA)
-			s.Expression()
-		}
+// separated list of expression.
+func (s *parser) ExpressionList() []jack_ast.Expr {
+	var list []jack_ast.Expr
+	if s.matches([]tokenpair{{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_RIGHT_PAREN}}) {
+		return list
+	}
+
+	list = append(list, s.Expression())
+	for s.matches([]tokenpair{
+		{jack_tokenizer.SYMBOL, jack_tokenizer.SYM_COMMA},
+	}) {
+		s.symbolHelper(jack_tokenizer.SYM_COMMA)
+		list = append(list, s.Expression())
 	}
-	io.WriteString(s.writer, "</expressionList>")
 
-	return count
+	return list
+}
+
+// ParseFile parses the class in ts into a syntax tree.
+func ParseFile(ts jack_tokenizer.TokenStream, opts ...Option) (*jack_ast.Class, error) {
+	return NewParser(ts, opts...).Parse()
 }
 
 // x* : 0 or more
@@ -552,9 +854,11 @@ func (s *parser) ExpressionList() int {
 // x | y x or y
 func ParseGrammar(tokens []jack_tokenizer.Token) func(io.Writer) error {
 	return func(w io.Writer) error {
-		parser := NewParser(tokens, w)
+		class, err := ParseFile(jack_tokenizer.NewSliceStream(tokens))
+		if err != nil {
+			return err
+		}
 
-		err := parser.Parse()
-		return err
+		return WriteXML(w, class)
 	}
 }
@@ -1,11 +1,14 @@
 package jack_parser
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	jack_ast "github.com/renojcpp/n2t-compiler/ast"
 	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
 )
 
@@ -13,10 +16,9 @@ func TestParseGrammar(t *testing.T) {
 	tests := []struct {
 		name string
 	}{
-		// TODO: Add test cases
-		{"/home/jr/school/cs3650/nand2tetris/projects/10/ArrayTest"},
-		{"/home/jr/school/cs3650/nand2tetris/projects/10/ExpressionLessSquare"},
-		{"/home/jr/school/cs3650/nand2tetris/projects/10/Square"},
+		{"testdata/ArrayTest"},
+		{"testdata/ExpressionLessSquare"},
+		{"testdata/Square"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -50,3 +52,501 @@ func TestParseGrammar(t *testing.T) {
 		})
 	}
 }
+
+func TestParseFileBuildsTree(t *testing.T) {
+	src := "class Main {\n  field int x;\n  method void f(int a) {\n    let x = a + 1;\n    do g(x);\n    return;\n  }\n}\n"
+	class, err := ParseFile(jack_tokenizer.NewLexer(strings.NewReader(src)))
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+
+	if class.Name.Name != "Main" || len(class.Vars) != 1 || len(class.Subroutines) != 1 {
+		t.Fatalf("got class %s with %d vars and %d subroutines, wanted Main with 1 and 1", class.Name.Name, len(class.Vars), len(class.Subroutines))
+	}
+	if got, want := class.End().Offset, len(src)-1; got != want {
+		t.Errorf("class end: got offset %d, wanted %d", got, want)
+	}
+
+	stmts := class.Subroutines[0].Body.Stmts
+	tests := []struct {
+		node jack_ast.Node
+		pos  string
+		end  string
+	}{
+		{stmts[0], "4:5", "4:19"},
+		{stmts[0].(*jack_ast.LetStmt).Value, "4:13", "4:18"},
+		{stmts[1], "5:5", "5:13"},
+		{stmts[1].(*jack_ast.DoStmt).Call, "5:8", "5:12"},
+		{stmts[2], "6:5", "6:12"},
+	}
+	for _, tt := range tests {
+		if got := tt.node.Pos().String(); got != tt.pos {
+			t.Errorf("%T pos: got %s, wanted %s", tt.node, got, tt.pos)
+		}
+		if got := tt.node.End().String(); got != tt.end {
+			t.Errorf("%T end: got %s, wanted %s", tt.node, got, tt.end)
+		}
+	}
+
+	bin, ok := stmts[0].(*jack_ast.LetStmt).Value.(*jack_ast.BinaryExpr)
+	if !ok || bin.Op != jack_tokenizer.SYM_PLUS {
+		t.Errorf("let value: got %#v, wanted a + b", stmts[0].(*jack_ast.LetStmt).Value)
+	}
+}
+
+func TestParseRecovery(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			"statements",
+			"class Main {\n  function void main() {\n    let x = ;\n    let y = 2\n    do Output.print(;\n    var int z;\n    x = 1;\n    return;\n  }\n}\n",
+			[]string{
+				"3:13: expected expression, found ';'",
+				"5:5: expected ';', found 'do'",
+				"5:21: expected expression, found ';'",
+				"6:5: expected statement, found 'var'",
+				"7:5: expected statement, found identifier x",
+			},
+		},
+		{
+			"declarations",
+			"class Main {\n  function void f() { return; }\n  field int q;\n  method void g( { }\n  function int h() { return 1 + ; }\n}\n",
+			[]string{
+				"3:3: expected subroutine declaration, found 'field'",
+				"4:18: expected ')', found '{'",
+				"5:33: expected expression, found ';'",
+			},
+		},
+		{
+			"skipped block",
+			"class Main {\n  function void main() {\n    if (x { let y = 1; }\n    let z = ;\n  }\n}\n",
+			[]string{
+				"3:11: expected ')', found '{'",
+				"4:13: expected expression, found ';'",
+			},
+		},
+		{"trailing", "class Main { } extra", []string{"1:16: expected end of file, found identifier extra"}},
+		{"unclosed", "class Main { function void main() { return; }", []string{"1:46: expected '}', found end of file"}},
+		{"lexical", "class Main { function void main() { let s = \"open;\n return; } }", []string{"1:45: string literal not terminated"}},
+		{
+			"lexical and unclosed",
+			"class Main { function void main() { let s = \"open;\n return; }",
+			[]string{"1:45: string literal not terminated", "2:11: expected '}', found end of file"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseFile(jack_tokenizer.NewLexer(strings.NewReader(tt.src)))
+			diags, ok := err.(jack_tokenizer.Diagnostics)
+			if !ok {
+				t.Fatalf("got error %v, wanted Diagnostics", err)
+			}
+
+			var got []string
+			for _, diag := range diags {
+				got = append(got, fmt.Sprintf("%s: %s", diag.Pos, diag.Msg))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got\n%s\nwanted\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+// TestWriteXMLMatchesReference compares the parse tree of every .jack
+// file in testdata with the project-10 .xml file next to it.
+func TestWriteXMLMatchesReference(t *testing.T) {
+	sources, err := filepath.Glob("testdata/*/*.jack")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, source := range sources {
+		reference := strings.TrimSuffix(source, ".jack") + ".xml"
+		t.Run(reference, func(t *testing.T) {
+			want, err := os.ReadFile(reference)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			file, err := os.Open(source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			class, err := ParseFile(jack_tokenizer.NewLexer(file))
+			if err != nil {
+				t.Fatalf("failed to parse: %s", err)
+			}
+
+			var ss strings.Builder
+			if err := WriteXML(&ss, class); err != nil {
+				t.Fatal(err)
+			}
+
+			// the reference files use CRLF line endings, which the
+			// course's comparer ignores
+			if got := ss.String(); got != strings.ReplaceAll(string(want), "\r\n", "\n") {
+				t.Errorf("got\n%s\nwanted\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	src := "class Point {\n  field int x;\n  static Point origin;\n  method int add(int dx) {\n    var int r;\n    let r = x + dx;\n    return r;\n  }\n}\n"
+	class, err := ParseFile(jack_tokenizer.NewLexer(strings.NewReader(src), jack_tokenizer.WithFilename("Point.jack")))
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+
+	var ss strings.Builder
+	if err := WriteJSON(&ss, class); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Version int
+		File    string
+		Class   *jsonNode
+	}
+	if err := json.Unmarshal([]byte(ss.String()), &doc); err != nil {
+		t.Fatalf("failed to read back: %s", err)
+	}
+	if doc.Version != JSONVersion || doc.File != "Point.jack" || doc.Class.Kind != "Class" {
+		t.Fatalf("got version %d, file %q and root %s", doc.Version, doc.File, doc.Class.Kind)
+	}
+
+	// let r = x + dx;
+	let := doc.Class.Children[3].Children[3].Children[1]
+	value := let.Children[1]
+	tests := []struct {
+		node *jsonNode
+		want jsonSymbol
+	}{
+		{let.Children[0], jsonSymbol{"local", "int", 0}},
+		{value.Children[0], jsonSymbol{"field", "int", 0}},
+		{value.Children[1], jsonSymbol{"argument", "int", 1}},
+	}
+	for _, tt := range tests {
+		if tt.node.Symbol == nil || *tt.node.Symbol != tt.want {
+			t.Errorf("%s: got %v, wanted %v", *tt.node.Value, tt.node.Symbol, tt.want)
+		}
+	}
+	if got := fmt.Sprintf("%s %s %d:%d-%d:%d", let.Kind, let.Role, let.Range.Start.Line, let.Range.Start.Column, let.Range.End.Line, let.Range.End.Column); got != "LetStmt stmt 6:5-6:20" {
+		t.Errorf("got %s, wanted LetStmt stmt 6:5-6:20", got)
+	}
+	if *value.Value != "+" {
+		t.Errorf("got operator %s, wanted +", *value.Value)
+	}
+}
+
+func TestJSONSchemaVersion(t *testing.T) {
+	src, err := os.ReadFile("ast.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var schema struct {
+		Properties struct {
+			Version struct {
+				Const int
+			}
+		}
+	}
+	if err := json.Unmarshal(src, &schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %s", err)
+	}
+	if got := schema.Properties.Version.Const; got != JSONVersion {
+		t.Errorf("got schema version %d, wanted %d", got, JSONVersion)
+	}
+}
+
+// group writes expr with every binary operation in parentheses.
+func group(expr jack_ast.Expr) string {
+	switch expr := expr.(type) {
+	case *jack_ast.BinaryExpr:
+		return "(" + group(expr.X) + " " + expr.Op.Spelling() + " " + group(expr.Y) + ")"
+	case *jack_ast.UnaryExpr:
+		return expr.Op.Spelling() + group(expr.X)
+	case *jack_ast.ParenExpr:
+		return group(expr.X)
+	case *jack_ast.Ident:
+		return expr.Name
+	case *jack_ast.IntLit:
+		return expr.Value
+	}
+	return "?"
+}
+
+func TestPrecedence(t *testing.T) {
+	tests := []struct {
+		expr     string
+		standard string
+		warning  string
+		conv     string
+	}{
+		{"1 + 2 * 3", "((1 + 2) * 3)", "1:46: '*' is applied after the '+' before it", "(1 + (2 * 3))"},
+		{"1 * 2 + 3", "((1 * 2) + 3)", "", "((1 * 2) + 3)"},
+		{"a - b - c", "((a - b) - c)", "", "((a - b) - c)"},
+		{"a | b & c", "((a | b) & c)", "1:46: '&' is applied after the '|' before it", "(a | (b & c))"},
+		{"a < b + 1", "((a < b) + 1)", "1:46: '+' is applied after the '<' before it", "(a < (b + 1))"},
+		{"a + 1 < b & c = d", "((((a + 1) < b) & c) = d)", "1:54: '=' is applied after the '&' before it", "(((a + 1) < b) & (c = d))"},
+		{"a * (b + c) / -d", "((a * (b + c)) / -d)", "", "((a * (b + c)) / -d)"},
+		{"a + b * c * d", "(((a + b) * c) * d)", "1:46: '*' is applied after the '+' before it", "(a + ((b * c) * d))"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			src := "class Main { function int f() { return " + tt.expr + "; } }"
+
+			p := NewParser(jack_tokenizer.NewLexer(strings.NewReader(src)))
+			class, err := p.Parse()
+			if err != nil {
+				t.Fatalf("failed to parse: %s", err)
+			}
+			value := class.Subroutines[0].Body.Stmts[0].(*jack_ast.ReturnStmt).Value
+			if got := group(value); got != tt.standard {
+				t.Errorf("standard: got %s, wanted %s", got, tt.standard)
+			}
+
+			var warnings []string
+			for _, diag := range p.Diagnostics() {
+				warnings = append(warnings, fmt.Sprintf("%s: %s", diag.Pos, diag.Msg))
+			}
+			switch got := strings.Join(warnings, "\n"); {
+			case tt.warning == "" && got != "":
+				t.Errorf("got warning %s, wanted none", got)
+			case !strings.HasPrefix(got, tt.warning) || strings.Count(got, "\n") > 0:
+				t.Errorf("got warning %s, wanted %s", got, tt.warning)
+			}
+
+			p = NewParser(jack_tokenizer.NewLexer(strings.NewReader(src)), WithPrecedence())
+			class, err = p.Parse()
+			if err != nil {
+				t.Fatalf("failed to parse: %s", err)
+			}
+			value = class.Subroutines[0].Body.Stmts[0].(*jack_ast.ReturnStmt).Value
+			if got := group(value); got != tt.conv {
+				t.Errorf("precedence: got %s, wanted %s", got, tt.conv)
+			}
+			if diags := p.Diagnostics(); len(diags) > 0 {
+				t.Errorf("precedence: got %s, wanted no diagnostics", diags)
+			}
+		})
+	}
+}
+
+func TestWarningDoesNotHideErrors(t *testing.T) {
+	_, err := ParseFile(jack_tokenizer.NewLexer(strings.NewReader("class Main { function int f() { return 1 + 2 * 3")))
+	if err == nil {
+		t.Fatalf("got no error, wanted one for the unclosed class")
+	}
+}
+
+func TestExtendedStatements(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		standard []string
+		extended []string
+	}{
+		{
+			"else if",
+			"if (a) { } else if (b) { } else { }",
+			[]string{"1:45: 'else if' is not standard Jack; enable the extended statements dialect to use it"},
+			nil,
+		},
+		{
+			"for",
+			"for (let i = 0; i < 3; let i = i + 1) { if (i = 1) { continue; } break; }",
+			[]string{
+				"1:34: 'for' is not standard Jack; enable the extended statements dialect to use it",
+				"1:87: 'continue' is not standard Jack; enable the extended statements dialect to use it",
+				"1:99: 'break' is not standard Jack; enable the extended statements dialect to use it",
+			},
+			nil,
+		},
+		{
+			"empty for",
+			"for (;;) { break; }",
+			[]string{
+				"1:34: 'for' is not standard Jack; enable the extended statements dialect to use it",
+				"1:45: 'break' is not standard Jack; enable the extended statements dialect to use it",
+			},
+			nil,
+		},
+		{
+			"outside a loop",
+			"while (a) { } break; if (a) { continue; }",
+			[]string{
+				"1:48: 'break' is not standard Jack; enable the extended statements dialect to use it",
+				"1:64: 'continue' is not standard Jack; enable the extended statements dialect to use it",
+			},
+			[]string{"1:48: break is not in a loop", "1:64: continue is not in a loop"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "class Main { function void f() { " + tt.body + " return; } }"
+
+			for _, mode := range []struct {
+				lexer  []jack_tokenizer.Option
+				parser []Option
+				want   []string
+			}{
+				{nil, nil, tt.standard},
+				{[]jack_tokenizer.Option{jack_tokenizer.WithExtendedStatements()}, []Option{WithExtendedStatements()}, tt.extended},
+			} {
+				_, err := ParseFile(jack_tokenizer.NewLexer(strings.NewReader(src), mode.lexer...), mode.parser...)
+
+				var got []string
+				if diags, ok := err.(jack_tokenizer.Diagnostics); ok {
+					for _, diag := range diags {
+						got = append(got, fmt.Sprintf("%s: %s", diag.Pos, diag.Msg))
+					}
+				} else if err != nil {
+					t.Fatalf("got error %v, wanted Diagnostics", err)
+				}
+				if strings.Join(got, "\n") != strings.Join(mode.want, "\n") {
+					t.Errorf("extended %v: got\n%s\nwanted\n%s", mode.parser != nil, strings.Join(got, "\n"), strings.Join(mode.want, "\n"))
+				}
+			}
+		})
+	}
+}
+
+func TestForStatementTree(t *testing.T) {
+	src := "class Main { function void f() { for (let i = 0; i < 3; do g()) { } } }"
+	class, err := ParseFile(jack_tokenizer.NewLexer(strings.NewReader(src), jack_tokenizer.WithExtendedStatements()), WithExtendedStatements())
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+
+	stmt, ok := class.Subroutines[0].Body.Stmts[0].(*jack_ast.ForStmt)
+	if !ok {
+		t.Fatalf("got %T, wanted *ForStmt", class.Subroutines[0].Body.Stmts[0])
+	}
+	init, ok1 := stmt.Init.(*jack_ast.LetStmt)
+	post, ok2 := stmt.Post.(*jack_ast.DoStmt)
+	if !ok1 || !ok2 || stmt.Cond == nil {
+		t.Fatalf("got init %T, cond %T and post %T, wanted let, an expression and do", stmt.Init, stmt.Cond, stmt.Post)
+	}
+
+	tests := []struct {
+		node jack_ast.Node
+		pos  string
+		end  string
+	}{
+		{stmt, "1:34", "1:68"},
+		{init, "1:39", "1:49"}, // with its ;
+		{post, "1:57", "1:63"},
+	}
+	for _, tt := range tests {
+		if got := tt.node.Pos().String(); got != tt.pos {
+			t.Errorf("%T pos: got %s, wanted %s", tt.node, got, tt.pos)
+		}
+		if got := tt.node.End().String(); got != tt.end {
+			t.Errorf("%T end: got %s, wanted %s", tt.node, got, tt.end)
+		}
+	}
+}
+
+func TestCompoundAssignment(t *testing.T) {
+	src := "class Main { function void f() { let a[i] += 1; let x |= y & z; return; } }"
+	lexer := func() jack_tokenizer.TokenStream {
+		return jack_tokenizer.NewLexer(strings.NewReader(src), jack_tokenizer.WithCompoundAssignment())
+	}
+
+	want := []string{
+		"1:43: '+=' is not standard Jack; enable the compound assignment dialect to use it",
+		"1:55: '|=' is not standard Jack; enable the compound assignment dialect to use it",
+	}
+	// whether or not the lexer makes single symbols of them
+	for _, ts := range []jack_tokenizer.TokenStream{lexer(), jack_tokenizer.NewLexer(strings.NewReader(src))} {
+		_, err := ParseFile(ts)
+		diags, _ := err.(jack_tokenizer.Diagnostics)
+		var got []string
+		for _, diag := range diags {
+			got = append(got, fmt.Sprintf("%s: %s", diag.Pos, diag.Msg))
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("standard: got\n%s\nwanted\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+
+	class, err := ParseFile(lexer(), WithCompoundAssignment())
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+	stmts := class.Subroutines[0].Body.Stmts
+	tests := []struct {
+		stmt   *jack_ast.LetStmt
+		assign jack_tokenizer.TokenSubtype
+		pos    string
+	}{
+		{stmts[0].(*jack_ast.LetStmt), jack_tokenizer.SYM_PLUS_EQUALS, "1:43"},
+		{stmts[1].(*jack_ast.LetStmt), jack_tokenizer.SYM_PIPE_EQUALS, "1:55"},
+	}
+	for _, tt := range tests {
+		if tt.stmt.Assign != tt.assign || tt.stmt.AssignPos.String() != tt.pos {
+			t.Errorf("got %v at %s, wanted %v at %s", tt.stmt.Assign, tt.stmt.AssignPos, tt.assign, tt.pos)
+		}
+	}
+	if _, ok := stmts[1].(*jack_ast.LetStmt).Value.(*jack_ast.BinaryExpr); !ok {
+		t.Errorf("got value %T, wanted the whole of y & z", stmts[1].(*jack_ast.LetStmt).Value)
+	}
+}
+
+func TestConstants(t *testing.T) {
+	src := "class Main { const int MAX = 512; field int x; enum Dir { UP, DOWN } function int f() { return Game.MAX + Dir.UP + Math.abs(x); } }"
+
+	_, err := ParseFile(jack_tokenizer.NewLexer(strings.NewReader(src)))
+	diags, _ := err.(jack_tokenizer.Diagnostics)
+	var got []string
+	for _, diag := range diags {
+		got = append(got, fmt.Sprintf("%s: %s", diag.Pos, diag.Msg))
+	}
+	want := []string{
+		"1:14: 'const' is not standard Jack; enable the constants dialect to use it",
+		"1:48: 'enum' is not standard Jack; enable the constants dialect to use it",
+		"1:105: expected '(', found '+'",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("standard: got\n%s\nwanted\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	lexer := jack_tokenizer.NewLexer(strings.NewReader(src), jack_tokenizer.WithConstants())
+	class, err := ParseFile(lexer, WithConstants())
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+
+	if len(class.Consts) != 1 || class.Consts[0].Name.Name != "MAX" || class.Consts[0].Type.Keyword != jack_tokenizer.KW_INT {
+		t.Errorf("got consts %v, wanted int MAX", class.Consts)
+	}
+	if len(class.Enums) != 1 || class.Enums[0].Name.Name != "Dir" || len(class.Enums[0].Members) != 2 {
+		t.Errorf("got enums %v, wanted Dir with 2 members", class.Enums)
+	}
+	var kinds []string
+	for _, dec := range class.Decls() {
+		kinds = append(kinds, fmt.Sprintf("%T", dec))
+	}
+	if got := strings.Join(kinds, " "); got != "*jack_ast.ConstDec *jack_ast.ClassVarDec *jack_ast.EnumDec" {
+		t.Errorf("got declarations %s, wanted them in source order", got)
+	}
+
+	// Game.MAX + Dir.UP + Math.abs(x)
+	value := class.Subroutines[0].Body.Stmts[0].(*jack_ast.ReturnStmt).Value.(*jack_ast.BinaryExpr)
+	if _, ok := value.Y.(*jack_ast.CallExpr); !ok {
+		t.Errorf("got %T, wanted a call", value.Y)
+	}
+	for _, x := range []jack_ast.Expr{value.X.(*jack_ast.BinaryExpr).X, value.X.(*jack_ast.BinaryExpr).Y} {
+		if _, ok := x.(*jack_ast.SelectorExpr); !ok {
+			t.Errorf("got %T, wanted a selector", x)
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package jack_parser
+
+import (
+	"io"
+	"strings"
+
+	jack_ast "github.com/renojcpp/n2t-compiler/ast"
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+	jack_tokenwriter "github.com/renojcpp/n2t-compiler/tokenwriter"
+)
+
+type xmlWriter struct {
+	w     io.Writer
+	depth int
+	err   error
+}
+
+// WriteXML writes the parse tree of class in the format of the
+// nand2tetris project 10 reference files: one element per grammar rule
+// with the tokens as leaves, each on its own line and indented by two
+// spaces per level. Lines end in "\n".
+func WriteXML(w io.Writer, class *jack_ast.Class) error {
+	x := &xmlWriter{w: w}
+	x.class(class)
+
+	return x.err
+}
+
+func (x *xmlWriter) write(s string) {
+	if x.err == nil {
+		_, x.err = io.WriteString(x.w, s)
+	}
+}
+
+func (x *xmlWriter) indent() {
+	x.write(strings.Repeat("  ", x.depth))
+}
+
+func (x *xmlWriter) open(tag string) {
+	x.indent()
+	x.write("<" + tag + ">\n")
+	x.depth++
+}
+
+func (x *xmlWriter) close(tag string) {
+	x.depth--
+	x.indent()
+	x.write("</" + tag + ">\n")
+}
+
+func (x *xmlWriter) leaf(tag, text string) {
+	x.indent()
+	x.write("<" + tag + "> " + jack_tokenwriter.EscapeXML(text) + " </" + tag + ">\n")
+}
+
+func (x *xmlWriter) keyword(st jack_tokenizer.TokenSubtype) {
+	x.leaf("keyword", st.Spelling())
+}
+
+func (x *xmlWriter) symbol(st jack_tokenizer.TokenSubtype) {
+	x.leaf("symbol", st.Spelling())
+}
+
+// semicolon writes the ';' ending a statement, which the clauses in
+// the header of a for loop go without.
+func (x *xmlWriter) semicolon(pos jack_tokenizer.Position) {
+	if pos.IsValid() {
+		x.symbol(jack_tokenizer.SYM_SEMICOLON)
+	}
+}
+
+func (x *xmlWriter) identifier(id *jack_ast.Ident) {
+	x.leaf("identifier", id.Name)
+}
+
+func (x *xmlWriter) typeName(t *jack_ast.TypeName) {
+	if t.Keyword != jack_tokenizer.NONE {
+		x.keyword(t.Keyword)
+		return
+	}
+	x.leaf("identifier", t.Name)
+}
+
+// names writes varName (',' varName)*.
+func (x *xmlWriter) names(names []*jack_ast.Ident) {
+	for i, name := range names {
+		if i > 0 {
+			x.symbol(jack_tokenizer.SYM_COMMA)
+		}
+		x.identifier(name)
+	}
+}
+
+func (x *xmlWriter) class(class *jack_ast.Class) {
+	x.open("class")
+	x.keyword(jack_tokenizer.KW_CLASS)
+	x.identifier(class.Name)
+	x.symbol(jack_tokenizer.SYM_LEFT_BRACE)
+
+	for _, dec := range class.Decls() {
+		switch dec := dec.(type) {
+		case *jack_ast.ClassVarDec:
+			x.open("classVarDec")
+			x.keyword(dec.Keyword)
+			x.typeName(dec.Type)
+			x.names(dec.Names)
+			x.symbol(jack_tokenizer.SYM_SEMICOLON)
+			x.close("classVarDec")
+
+		// The constants dialect follows the pattern of classVarDec.
+		case *jack_ast.ConstDec:
+			x.open("constDec")
+			x.keyword(jack_tokenizer.KW_CONST)
+			x.typeName(dec.Type)
+			x.identifier(dec.Name)
+			x.symbol(jack_tokenizer.SYM_EQUALS)
+			x.expression(dec.Value)
+			x.symbol(jack_tokenizer.SYM_SEMICOLON)
+			x.close("constDec")
+		case *jack_ast.EnumDec:
+			x.open("enumDec")
+			x.keyword(jack_tokenizer.KW_ENUM)
+			x.identifier(dec.Name)
+			x.symbol(jack_tokenizer.SYM_LEFT_BRACE)
+			x.names(dec.Members)
+			x.symbol(jack_tokenizer.SYM_RIGHT_BRACE)
+			x.close("enumDec")
+		}
+	}
+
+	for _, dec := range class.Subroutines {
+		x.subroutine(dec)
+	}
+
+	x.symbol(jack_tokenizer.SYM_RIGHT_BRACE)
+	x.close("class")
+}
+
+func (x *xmlWriter) subroutine(dec *jack_ast.SubroutineDec) {
+	x.open("subroutineDec")
+	x.keyword(dec.Keyword)
+	x.typeName(dec.Return)
+	x.identifier(dec.Name)
+	x.symbol(jack_tokenizer.SYM_LEFT_PAREN)
+
+	x.open("parameterList")
+	for i, param := range dec.Params {
+		if i > 0 {
+			x.symbol(jack_tokenizer.SYM_COMMA)
+		}
+		x.typeName(param.Type)
+		x.identifier(param.Name)
+	}
+	x.close("parameterList")
+
+	x.symbol(jack_tokenizer.SYM_RIGHT_PAREN)
+
+	x.open("subroutineBody")
+	x.symbol(jack_tokenizer.SYM_LEFT_BRACE)
+	for _, dec := range dec.Body.Vars {
+		x.open("varDec")
+		x.keyword(jack_tokenizer.KW_VAR)
+		x.typeName(dec.Type)
+		x.names(dec.Names)
+		x.symbol(jack_tokenizer.SYM_SEMICOLON)
+		x.close("varDec")
+	}
+	x.statements(dec.Body.Stmts)
+	x.symbol(jack_tokenizer.SYM_RIGHT_BRACE)
+	x.close("subroutineBody")
+
+	x.close("subroutineDec")
+}
+
+func (x *xmlWriter) statements(stmts []jack_ast.Stmt) {
+	x.open("statements")
+	for _, stmt := range stmts {
+		x.statement(stmt)
+	}
+	x.close("statements")
+}
+
+func (x *xmlWriter) block(block *jack_ast.Block) {
+	x.symbol(jack_tokenizer.SYM_LEFT_BRACE)
+	x.statements(block.Stmts)
+	x.symbol(jack_tokenizer.SYM_RIGHT_BRACE)
+}
+
+func (x *xmlWriter) statement(stmt jack_ast.Stmt) {
+	switch stmt := stmt.(type) {
+	case *jack_ast.LetStmt:
+		x.open("letStatement")
+		x.keyword(jack_tokenizer.KW_LET)
+		x.identifier(stmt.Name)
+		if stmt.Index != nil {
+			x.symbol(jack_tokenizer.SYM_LEFT_BRACK)
+			x.expression(stmt.Index)
+			x.symbol(jack_tokenizer.SYM_RIGHT_BRACK)
+		}
+		if _, ok := stmt.Assign.AssignOp(); ok {
+			x.symbol(stmt.Assign)
+		} else {
+			x.symbol(jack_tokenizer.SYM_EQUALS)
+		}
+		x.expression(stmt.Value)
+		x.semicolon(stmt.Semicolon)
+		x.close("letStatement")
+	case *jack_ast.IfStmt:
+		x.open("ifStatement")
+		x.keyword(jack_tokenizer.KW_IF)
+		x.symbol(jack_tokenizer.SYM_LEFT_PAREN)
+		x.expression(stmt.Cond)
+		x.symbol(jack_tokenizer.SYM_RIGHT_PAREN)
+		x.block(stmt.Body)
+		if stmt.Else != nil {
+			x.keyword(jack_tokenizer.KW_ELSE)
+			if block, ok := stmt.Else.(*jack_ast.Block); ok {
+				x.block(block)
+			} else {
+				x.statement(stmt.Else)
+			}
+		}
+		x.close("ifStatement")
+	case *jack_ast.WhileStmt:
+		x.open("whileStatement")
+		x.keyword(jack_tokenizer.KW_WHILE)
+		x.symbol(jack_tokenizer.SYM_LEFT_PAREN)
+		x.expression(stmt.Cond)
+		x.symbol(jack_tokenizer.SYM_RIGHT_PAREN)
+		x.block(stmt.Body)
+		x.close("whileStatement")
+	case *jack_ast.DoStmt:
+		x.open("doStatement")
+		x.keyword(jack_tokenizer.KW_DO)
+		x.call(stmt.Call)
+		x.semicolon(stmt.Semicolon)
+		x.close("doStatement")
+	case *jack_ast.ReturnStmt:
+		x.open("returnStatement")
+		x.keyword(jack_tokenizer.KW_RETURN)
+		if stmt.Value != nil {
+			x.expression(stmt.Value)
+		}
+		x.symbol(jack_tokenizer.SYM_SEMICOLON)
+		x.close("returnStatement")
+
+	// The extended statements follow the pattern of the others.
+	case *jack_ast.ForStmt:
+		x.open("forStatement")
+		x.keyword(jack_tokenizer.KW_FOR)
+		x.symbol(jack_tokenizer.SYM_LEFT_PAREN)
+		if stmt.Init != nil {
+			x.statement(stmt.Init)
+		} else {
+			x.symbol(jack_tokenizer.SYM_SEMICOLON)
+		}
+		if stmt.Cond != nil {
+			x.expression(stmt.Cond)
+		}
+		x.symbol(jack_tokenizer.SYM_SEMICOLON)
+		if stmt.Post != nil {
+			x.statement(stmt.Post)
+		}
+		x.symbol(jack_tokenizer.SYM_RIGHT_PAREN)
+		x.block(stmt.Body)
+		x.close("forStatement")
+	case *jack_ast.BreakStmt:
+		x.open("breakStatement")
+		x.keyword(jack_tokenizer.KW_BREAK)
+		x.symbol(jack_tokenizer.SYM_SEMICOLON)
+		x.close("breakStatement")
+	case *jack_ast.ContinueStmt:
+		x.open("continueStatement")
+		x.keyword(jack_tokenizer.KW_CONTINUE)
+		x.symbol(jack_tokenizer.SYM_SEMICOLON)
+		x.close("continueStatement")
+	}
+}
+
+// expression writes expr as a list of terms separated by operators.
+// Jack combines terms left to right, so a BinaryExpr only ever nests
+// on its left, except when parsed WithPrecedence; the grammar has no
+// room for that grouping, so it is flattened away.
+func (x *xmlWriter) expression(expr jack_ast.Expr) {
+	x.open("expression")
+	x.terms(expr)
+	x.close("expression")
+}
+
+func (x *xmlWriter) terms(expr jack_ast.Expr) {
+	if bin, ok := expr.(*jack_ast.BinaryExpr); ok {
+		x.terms(bin.X)
+		x.symbol(bin.Op)
+		x.terms(bin.Y)
+		return
+	}
+	x.term(expr)
+}
+
+func (x *xmlWriter) term(expr jack_ast.Expr) {
+	x.open("term")
+	switch expr := expr.(type) {
+	case *jack_ast.Ident:
+		x.identifier(expr)
+	case *jack_ast.IntLit:
+		x.leaf("integerConstant", expr.Value)
+	case *jack_ast.StringLit:
+		x.leaf("stringConstant", expr.Value)
+	case *jack_ast.KeywordLit:
+		x.keyword(expr.Keyword)
+	case *jack_ast.ParenExpr:
+		x.symbol(jack_tokenizer.SYM_LEFT_PAREN)
+		x.expression(expr.X)
+		x.symbol(jack_tokenizer.SYM_RIGHT_PAREN)
+	case *jack_ast.UnaryExpr:
+		x.symbol(expr.Op)
+		x.term(expr.X)
+	case *jack_ast.IndexExpr:
+		x.identifier(expr.X)
+		x.symbol(jack_tokenizer.SYM_LEFT_BRACK)
+		x.expression(expr.Index)
+		x.symbol(jack_tokenizer.SYM_RIGHT_BRACK)
+	case *jack_ast.CallExpr:
+		x.call(expr)
+	case *jack_ast.SelectorExpr:
+		x.identifier(expr.X)
+		x.symbol(jack_tokenizer.SYM_PERIOD)
+		x.identifier(expr.Sel)
+	}
+	x.close("term")
+}
+
+// call writes a subroutine call. The reference files have no element
+// for the call itself, so its tokens sit directly in the enclosing term
+// or doStatement.
+func (x *xmlWriter) call(call *jack_ast.CallExpr) {
+	if call.Receiver != nil {
+		x.identifier(call.Receiver)
+		x.symbol(jack_tokenizer.SYM_PERIOD)
+	}
+	x.identifier(call.Name)
+	x.symbol(jack_tokenizer.SYM_LEFT_PAREN)
+
+	x.open("expressionList")
+	for i, arg := range call.Args {
+		if i > 0 {
+			x.symbol(jack_tokenizer.SYM_COMMA)
+		}
+		x.expression(arg)
+	}
+	x.close("expressionList")
+
+	x.symbol(jack_tokenizer.SYM_RIGHT_PAREN)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+	jack_tokenwriter "github.com/renojcpp/n2t-compiler/tokenwriter"
+)
+
+func runTokenize(args []string) int {
+	fs := flag.NewFlagSet("tokenize", flag.ExitOnError)
+	format := fs.String("format", "xml", "output format: "+strings.Join(jack_tokenwriter.Formats, ", "))
+	options := lexerFlags(fs)
+	fs.Parse(args)
+
+	files, err := jackFiles(fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	status := 0
+	for _, name := range files {
+		tw, err := jack_tokenwriter.NewWriter(*format, os.Stdout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+
+		file, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open file: %s\n", name)
+			status = 1
+			continue
+		}
+
+		tokens, err := jack_tokenizer.Tokenize(file, options(filepath.Base(name))...)
+		file.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+		}
+
+		if err := jack_tokenwriter.WriteAll(tw, tokens); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	return status
+}
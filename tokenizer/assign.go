@@ -0,0 +1,36 @@
+package jack_tokenizer
+
+// WithCompoundAssignment lexes +=, -=, *=, /=, &= and |= as single
+// symbols, for the compound assignments of the parser. Without it they
+// are two symbols each, as in standard Jack.
+func WithCompoundAssignment() Option {
+	return func(o *options) {
+		o.compound = true
+	}
+}
+
+var compoundSymbols = luxmap{
+	"+=": {SYMBOL, SYM_PLUS_EQUALS},
+	"-=": {SYMBOL, SYM_MINUS_EQUALS},
+	"*=": {SYMBOL, SYM_ASTERISK_EQUALS},
+	"/=": {SYMBOL, SYM_SLASH_EQUALS},
+	"&=": {SYMBOL, SYM_AMPERSAND_EQUALS},
+	"|=": {SYMBOL, SYM_PIPE_EQUALS},
+}
+
+var compoundOps = map[TokenSubtype]TokenSubtype{
+	SYM_PLUS_EQUALS:      SYM_PLUS,
+	SYM_MINUS_EQUALS:     SYM_MINUS,
+	SYM_ASTERISK_EQUALS:  SYM_ASTERISK,
+	SYM_SLASH_EQUALS:     SYM_SLASH,
+	SYM_AMPERSAND_EQUALS: SYM_AMPERSAND,
+	SYM_PIPE_EQUALS:      SYM_PIPE,
+}
+
+// AssignOp returns the binary operator the compound assignment st
+// applies, as SYM_PLUS for SYM_PLUS_EQUALS. It reports false if st is
+// not a compound assignment.
+func (st TokenSubtype) AssignOp() (TokenSubtype, bool) {
+	op, ok := compoundOps[st]
+	return op, ok
+}
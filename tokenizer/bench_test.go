@@ -0,0 +1,132 @@
+package jack_tokenizer
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+// corpusSize is roughly how much Jack source the benchmarks chew on.
+const corpusSize = 4 << 20
+
+var (
+	corpusOnce sync.Once
+	corpus     []byte
+)
+
+// benchCorpus generates a few megabytes of Jack classes that use every
+// kind of token, comment and whitespace.
+func benchCorpus() []byte {
+	corpusOnce.Do(func() {
+		var ss strings.Builder
+		for i := 0; ss.Len() < corpusSize; i++ {
+			fmt.Fprintf(&ss, `/** Generated class number %[1]d. */
+class Generated%[1]d {
+    static int count%[1]d;
+    field Array cells;
+    field int width, height; // dimensions
+
+    constructor Generated%[1]d new(int w, int h) {
+        let width = w;
+        let height = h;
+        let cells = Array.new(w * h);
+        return this;
+    }
+
+    /* Sums the cells, skipping negative ones. */
+    method int sum() {
+        var int i, total;
+        let i = 0;
+        let total = 0;
+        while (i < (width * height)) {
+            if (~(cells[i] < 0)) {
+                let total = total + cells[i];
+            } else {
+                do Output.printString("negative cell at index %[1]d");
+            }
+            let i = i + 1;
+        }
+        return total / %[2]d;
+    }
+}
+
+`, i, i%32767+1)
+		}
+		corpus = []byte(ss.String())
+	})
+
+	return corpus
+}
+
+func BenchmarkTokenize(b *testing.B) {
+	src := benchCorpus()
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Tokenize(bytes.NewReader(src)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestTokenizeAllocations checks that Tokenize allocates little more
+// than the source and one Token per token, as BenchmarkTokenize reports.
+func TestTokenizeAllocations(t *testing.T) {
+	src := benchCorpus()
+	src = src[:bytes.LastIndex(src[:256<<10], []byte("/**"))]
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	tokens, err := Tokenize(bytes.NewReader(src))
+	runtime.ReadMemStats(&after)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cap(tokens) != len(tokens)+1 {
+		t.Errorf("got capacity %d, wanted %d", cap(tokens), len(tokens)+1)
+	}
+	want := uint64(len(src)) + uint64(cap(tokens))*uint64(unsafe.Sizeof(Token{}))
+	if got := after.TotalAlloc - before.TotalAlloc; got > want+want/100 {
+		t.Errorf("got %d bytes allocated, wanted at most %d", got, want+want/100)
+	}
+}
+
+func BenchmarkTokenizeTrivia(b *testing.B) {
+	src := benchCorpus()
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Tokenize(bytes.NewReader(src), WithTrivia()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLexerNext(b *testing.B) {
+	src := benchCorpus()
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		lexer := NewLexer(bytes.NewReader(src))
+		for {
+			token, err := lexer.Next()
+			if err != nil {
+				b.Fatal(err)
+			}
+			if token.Tokentype == EOF {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package jack_tokenizer
+
+// byteClass is a set of flags describing what role a byte can play in
+// Jack source. The scanner looks bytes up in the classes table instead
+// of comparing against ranges or converting them to strings.
+type byteClass uint8
+
+const (
+	classIdentStart byteClass = 1 << iota // letters and '_'
+	classDigit
+	classHexDigit
+	classSpace   // whitespace other than line breaks
+	classNewline // '\n' and '\r'
+	classSymbol
+)
+
+const classIdent = classIdentStart | classDigit
+
+var (
+	classes     [256]byteClass
+	symbols     [256]tokenpair
+	digitValues [256]int8 // the value of each hex digit
+)
+
+func init() {
+	for c := 'a'; c <= 'z'; c++ {
+		classes[c] |= classIdentStart
+	}
+	for c := 'A'; c <= 'Z'; c++ {
+		classes[c] |= classIdentStart
+	}
+	classes['_'] |= classIdentStart
+
+	for c := '0'; c <= '9'; c++ {
+		classes[c] |= classDigit | classHexDigit
+		digitValues[c] = int8(c - '0')
+	}
+	for c := 'a'; c <= 'f'; c++ {
+		classes[c] |= classHexDigit
+		classes[c-'a'+'A'] |= classHexDigit
+		digitValues[c] = int8(c - 'a' + 10)
+		digitValues[c-'a'+'A'] = int8(c - 'a' + 10)
+	}
+
+	for _, c := range " \t\f\v" {
+		classes[c] |= classSpace
+	}
+	classes['\n'] |= classNewline
+	classes['\r'] |= classNewline
+
+	for lexeme, pair := range mp {
+		if pair.tt == SYMBOL {
+			classes[lexeme[0]] |= classSymbol
+			symbols[lexeme[0]] = pair
+		}
+	}
+}
+
+func (c byteClass) is(class byteClass) bool {
+	return c&class != 0
+}
+
+func isNumber(c byte) bool {
+	return classes[c].is(classDigit)
+}
+
+func isLetter(c byte) bool {
+	return classes[c].is(classIdentStart)
+}
+
+func isSpace(c byte) bool {
+	return classes[c].is(classSpace | classNewline)
+}
+
+func isNewline(c byte) bool {
+	return classes[c].is(classNewline)
+}
+
+func isHexDigit(c byte) bool {
+	return classes[c].is(classHexDigit)
+}
+
+func isBinaryDigit(c byte) bool {
+	return c == '0' || c == '1'
+}
@@ -0,0 +1,15 @@
+package jack_tokenizer
+
+// WithConstants makes const and enum keywords, for the constant and
+// enum declarations of the parser. Without it they are ordinary
+// identifiers, as in standard Jack.
+func WithConstants() Option {
+	return func(o *options) {
+		o.constants = true
+	}
+}
+
+var constantKeywords = luxmap{
+	"const": {KEYWORD, KW_CONST},
+	"enum":  {KEYWORD, KW_ENUM},
+}
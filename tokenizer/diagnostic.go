@@ -0,0 +1,95 @@
+package jack_tokenizer
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// Code identifies the kind of problem a Diagnostic reports. Codes are
+// stable, so tools may match on them instead of on the message text.
+type Code string
+
+const (
+	CodeUnterminatedString  Code = "unterminated-string"
+	CodeUnterminatedComment Code = "unterminated-comment"
+	CodeIllegalCharacter    Code = "illegal-character"
+	CodeMalformedNumber     Code = "malformed-number"
+	CodeInvalidEscape       Code = "invalid-escape"
+	CodeMalformedCharacter  Code = "malformed-character"
+)
+
+// Diagnostic is a single problem found in a source file.
+type Diagnostic struct {
+	Severity Severity
+	Code     Code
+	Pos      Position
+	Msg      string
+}
+
+func NewDiagnostic(severity Severity, code Code, pos Position, format string, args ...interface{}) Diagnostic {
+	return Diagnostic{
+		severity,
+		code,
+		pos,
+		fmt.Sprintf(format, args...),
+	}
+}
+
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("%s: %s: %s [%s]", d.Pos, d.Severity, d.Msg, d.Code)
+}
+
+// Diagnostics is a list of problems, in the order they were found. It
+// satisfies error so a whole list can be handed back at once.
+type Diagnostics []Diagnostic
+
+func (d Diagnostics) Error() string {
+	var ss strings.Builder
+	for i, diag := range d {
+		if i > 0 {
+			ss.WriteByte('\n')
+		}
+		ss.WriteString(diag.Error())
+	}
+
+	return ss.String()
+}
+
+// HasErrors reports whether any entry is an error rather than a warning.
+func (d Diagnostics) HasErrors() bool {
+	for _, diag := range d {
+		if diag.Severity == SeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Err returns the list as an error if it holds any errors, and nil
+// otherwise.
+func (d Diagnostics) Err() error {
+	if !d.HasErrors() {
+		return nil
+	}
+
+	return d
+}
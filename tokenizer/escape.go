@@ -0,0 +1,71 @@
+package jack_tokenizer
+
+// WithEscapes lets string constants use the escape sequences \n, \t,
+// \", \\ and \u{NNN}, where NNN is a decimal character code of at most
+// three digits. Token.Lexeme holds the decoded string; mapping it onto
+// the Hack character set is left to the compiler.
+func WithEscapes() Option {
+	return func(o *options) {
+		o.escapes = true
+	}
+}
+
+// scanEscape consumes an escape sequence starting at the backslash and
+// returns the byte it stands for. It reports a diagnostic and returns
+// false if the sequence is malformed.
+func (l *Lexer) scanEscape() (byte, bool) {
+	pos := l.position()
+	l.advance() // '\'
+
+	ch, ok := l.peekByte(0)
+	if !ok || isNewline(ch) {
+		l.report(CodeInvalidEscape, pos, "escape sequence not terminated")
+		return 0, false
+	}
+	l.advance()
+
+	switch ch {
+	case 'n':
+		return '\n', true
+	case 't':
+		return '\t', true
+	case '"':
+		return '"', true
+	case '\'':
+		return '\'', true
+	case '\\':
+		return '\\', true
+	case 'u':
+		if ch, _ := l.peekByte(0); ch != '{' {
+			l.report(CodeInvalidEscape, pos, "malformed \\u escape, wanted \\u{NNN}")
+			return 0, false
+		}
+		l.advance()
+
+		value, digits := 0, 0
+		for ch, ok := l.peekByte(0); ok && isNumber(ch); ch, ok = l.peekByte(0) {
+			value = value*10 + int(l.advance()-'0')
+			digits++
+		}
+
+		if ch, _ := l.peekByte(0); ch != '}' {
+			l.report(CodeInvalidEscape, pos, "malformed \\u escape, wanted \\u{NNN}")
+			return 0, false
+		}
+		l.advance()
+
+		if digits == 0 || digits > 3 {
+			l.report(CodeInvalidEscape, pos, "malformed \\u escape, wanted \\u{NNN}")
+			return 0, false
+		}
+
+		if value > 255 {
+			l.report(CodeInvalidEscape, pos, "character code %d in \\u escape is out of range", value)
+			return 0, false
+		}
+		return byte(value), true
+	default:
+		l.report(CodeInvalidEscape, pos, "unknown escape sequence \\%c", ch)
+		return 0, false
+	}
+}
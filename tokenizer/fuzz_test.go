@@ -0,0 +1,71 @@
+package jack_tokenizer
+
+import (
+	"strings"
+	"testing"
+)
+
+func sameTokens(a, b []Token) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Tokentype != b[i].Tokentype || a[i].Subtype != b[i].Subtype || a[i].Lexeme != b[i].Lexeme {
+			return false
+		}
+	}
+
+	return true
+}
+
+// fuzzOptions switches on a language extension for each bit set in
+// flags, so that the fuzzer explores every dialect.
+func fuzzOptions(flags uint8) []Option {
+	var opts []Option
+	if flags&1 != 0 {
+		opts = append(opts, WithExtendedStatements())
+	}
+	if flags&2 != 0 {
+		opts = append(opts, WithCompoundAssignment())
+	}
+	if flags&4 != 0 {
+		opts = append(opts, WithConstants())
+	}
+	if flags&8 != 0 {
+		opts = append(opts, WithEscapes(), WithExtendedLiterals())
+	}
+
+	return opts
+}
+
+// FuzzTokenize checks that tokenizing never gets stuck or panics, that
+// tokens rendered back to source tokenize to the same stream, and that
+// trivia mode reproduces its input byte for byte.
+func FuzzTokenize(f *testing.F) {
+	f.Fuzz(func(t *testing.T, src string, flags uint8) {
+		opts := fuzzOptions(flags)
+		if tokens, err := Tokenize(strings.NewReader(src), opts...); err == nil {
+			rendered := Render(tokens)
+			again, err := Tokenize(strings.NewReader(rendered), opts...)
+			if err != nil {
+				t.Fatalf("rendered source %q does not tokenize: %s", rendered, err)
+			}
+			if !sameTokens(tokens, again) {
+				t.Fatalf("rendered source %q tokenizes differently:\n%v\n%v", rendered, tokens, again)
+			}
+		}
+
+		lexer := NewLexer(strings.NewReader(src), append(opts, WithTrivia())...)
+		var ss strings.Builder
+		for {
+			token, _ := lexer.Next()
+			ss.WriteString(token.Source())
+			if token.Tokentype == EOF {
+				break
+			}
+		}
+		if ss.String() != src {
+			t.Fatalf("trivia round trip: got %q, wanted %q", ss.String(), src)
+		}
+	})
+}
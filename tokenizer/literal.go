@@ -0,0 +1,99 @@
+package jack_tokenizer
+
+import "strconv"
+
+// WithExtendedLiterals accepts hexadecimal (0x7FFF) and binary
+// (0b1010_0101) integer constants, with optional underscores between
+// digits, and character literals such as 'A' or '\n'. All of them come
+// out as INT_CONSTANT tokens whose Lexeme is the value in decimal, so
+// consumers that only understand decimal constants keep working;
+// Token.Raw still holds the literal as written.
+func WithExtendedLiterals() Option {
+	return func(o *options) {
+		o.literals = true
+	}
+}
+
+// maxLiteral bounds the value a literal can accumulate; anything past
+// it is out of range for Jack anyway, and the compiler says so.
+const maxLiteral = 1 << 31
+
+// radixPrefix reports whether the input continues with 0x or 0b.
+func (l *Lexer) radixPrefix() bool {
+	next, _ := l.peekByte(1)
+	return next == 'x' || next == 'X' || next == 'b' || next == 'B'
+}
+
+// scanRadix scans a 0x or 0b prefixed integer constant.
+func (l *Lexer) scanRadix(pos Position) Token {
+	l.advance() // '0'
+	prefix := l.advance()
+
+	base, isDigit := 16, isHexDigit
+	if prefix == 'b' || prefix == 'B' {
+		base, isDigit = 2, isBinaryDigit
+	}
+
+	value, digits, malformed := 0, 0, false
+	for ch, ok := l.peekByte(0); ok && classes[ch].is(classIdent); ch, ok = l.peekByte(0) {
+		l.advance()
+		switch {
+		case ch == '_':
+			malformed = malformed || digits == 0
+		case isDigit(ch):
+			if value < maxLiteral {
+				value = value*base + int(digitValues[ch])
+			}
+			digits++
+		default:
+			malformed = true
+		}
+	}
+
+	if raw := l.text(); digits == 0 || malformed || raw[len(raw)-1] == '_' {
+		l.report(CodeMalformedNumber, pos, "malformed number %s", raw)
+		return l.token(raw, pos, ERROR, NONE)
+	}
+
+	return l.token(strconv.Itoa(value), pos, INT_CONSTANT, NONE)
+}
+
+// scanCharacter scans a character literal such as 'A' or '\n'.
+func (l *Lexer) scanCharacter(pos Position) Token {
+	l.advance() // opening quote
+
+	var value byte
+	ok := false
+	switch ch, more := l.peekByte(0); {
+	case !more || isNewline(ch) || ch == '\'':
+	case ch == '\\':
+		value, ok = l.scanEscape()
+		if !ok {
+			// the escape has been reported already
+			l.skipCharacterLiteral()
+			return l.token(l.text(), pos, ERROR, NONE)
+		}
+	default:
+		value, ok = l.advance(), true
+	}
+
+	if ch, _ := l.peekByte(0); !ok || ch != '\'' {
+		l.skipCharacterLiteral()
+		l.report(CodeMalformedCharacter, pos, "malformed character literal %s", l.text())
+		return l.token(l.text(), pos, ERROR, NONE)
+	}
+	l.advance()
+
+	return l.token(strconv.Itoa(int(value)), pos, INT_CONSTANT, NONE)
+}
+
+// skipCharacterLiteral consumes the rest of a bad character literal,
+// up to its closing quote if there is one on the line.
+func (l *Lexer) skipCharacterLiteral() {
+	for ch, ok := l.peekByte(0); ok && !isNewline(ch) && !isSpace(ch); ch, ok = l.peekByte(0) {
+		l.advance()
+		if ch == '\'' {
+			return
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package jack_tokenizer
+
+// WithExtendedStatements makes for, break and continue keywords, for
+// the extended statements of the parser. Without it they are ordinary
+// identifiers, as in standard Jack.
+func WithExtendedStatements() Option {
+	return func(o *options) {
+		o.statements = true
+	}
+}
+
+var statementKeywords = luxmap{
+	"for":      {KEYWORD, KW_FOR},
+	"break":    {KEYWORD, KW_BREAK},
+	"continue": {KEYWORD, KW_CONTINUE},
+}
@@ -1,21 +1,80 @@
 package jack_tokenizer
 
+import (
+	"fmt"
+	"strings"
+)
+
+// Position describes a location in a source file. Line and Column are
+// 1-based, Column counts bytes, and Offset is the 0-based byte offset
+// from the start of the file.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+	Offset int
+}
+
+// IsValid reports whether the position has been set.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String formats the position as file:line:column, leaving out the
+// file name when it is unknown.
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.File != "" {
+			return p.File
+		}
+		return "-"
+	}
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
 type Token struct {
 	Lexeme    string
-	Line      int
+	Raw       string   // the token exactly as written, quotes and all
+	Pos       Position // first byte of the token
+	End       Position // one past the last byte of the token
 	Tokentype TokenType
 	Subtype   TokenSubtype
+
+	// Only filled in when lexing WithTrivia.
+	Leading  []Trivia
+	Trailing []Trivia
 }
 
-func NewToken(lexeme string, line int, tokentype TokenType, subtype TokenSubtype) Token {
+func NewToken(lexeme string, pos, end Position, tokentype TokenType, subtype TokenSubtype) Token {
 	return Token{
-		lexeme,
-		line,
-		tokentype,
-		subtype,
+		Lexeme:    lexeme,
+		Raw:       lexeme,
+		Pos:       pos,
+		End:       end,
+		Tokentype: tokentype,
+		Subtype:   subtype,
 	}
 }
 
+// Source returns the token as it appeared in the file, surrounded by
+// its trivia. Concatenating Source over every token up to and
+// including EOF reproduces the input of a WithTrivia lexer exactly.
+func (t Token) Source() string {
+	var ss strings.Builder
+	for _, tr := range t.Leading {
+		ss.WriteString(tr.Text)
+	}
+	ss.WriteString(t.Raw)
+	for _, tr := range t.Trailing {
+		ss.WriteString(tr.Text)
+	}
+
+	return ss.String()
+}
+
 type TokenType int
 
 const (
@@ -29,6 +88,7 @@ const (
 	INT_CONSTANT
 	STRING_CONSTANT
 	IDENTIFIER
+	EOF
 )
 
 type TokenSubtype int
@@ -59,6 +119,11 @@ const (
 	KW_ELSE
 	KW_WHILE
 	KW_RETURN
+	KW_FOR      // WithExtendedStatements only
+	KW_BREAK    // WithExtendedStatements only
+	KW_CONTINUE // WithExtendedStatements only
+	KW_CONST    // WithConstants only
+	KW_ENUM     // WithConstants only
 
 	// symbols
 	SYM_LEFT_BRACE
@@ -80,6 +145,14 @@ const (
 	SYM_GREATER_THAN
 	SYM_EQUALS
 	SYM_TILDE
+
+	// compound assignments, WithCompoundAssignment only
+	SYM_PLUS_EQUALS
+	SYM_MINUS_EQUALS
+	SYM_ASTERISK_EQUALS
+	SYM_SLASH_EQUALS
+	SYM_AMPERSAND_EQUALS
+	SYM_PIPE_EQUALS
 )
 
 type tokenpair struct {
@@ -132,3 +205,19 @@ var mp = luxmap{
 	"=": {SYMBOL, SYM_EQUALS},
 	"~": {SYMBOL, SYM_TILDE},
 }
+
+var spellings = func() map[TokenSubtype]string {
+	m := make(map[TokenSubtype]string, len(mp))
+	for _, table := range []luxmap{mp, statementKeywords, constantKeywords, compoundSymbols} {
+		for lexeme, pair := range table {
+			m[pair.st] = lexeme
+		}
+	}
+	return m
+}()
+
+// Spelling returns how the keyword or symbol st is written in source,
+// or "" if st is neither.
+func (st TokenSubtype) Spelling() string {
+	return spellings[st]
+}
@@ -3,149 +3,462 @@ package jack_tokenizer
 import (
 	"errors"
 	"io"
-	"strconv"
 	"strings"
 )
 
-type scanner struct {
-	bytes []byte
-	index int
+type options struct {
+	filename   string
+	trivia     bool
+	escapes    bool
+	literals   bool
+	statements bool
+	compound   bool
+	constants  bool
 }
 
-func (s *scanner) atEnd() bool {
-	return s.index >= len(s.bytes)
+// An Option configures how source is tokenized.
+type Option func(*options)
+
+// WithFilename sets the file name recorded in every token's Position.
+func WithFilename(name string) Option {
+	return func(o *options) {
+		o.filename = name
+	}
 }
 
-func (s *scanner) advance() {
-	s.index++
+// A TokenStream hands out tokens one at a time. Once the input is
+// exhausted, Next and Peek keep returning an EOF token.
+type TokenStream interface {
+	// Next consumes and returns the next token.
+	Next() (Token, error)
+	// Peek returns the token n places ahead without consuming
+	// anything; Peek(0) is the token the next call to Next returns.
+	Peek(n int) (Token, error)
 }
 
-func (s *scanner) peek() (byte, error) {
-	if s.atEnd() || s.index+1 >= len(s.bytes) {
-		return 0, errors.New("peek beyond")
-	}
+// chunkSize is how much a Lexer reads from its reader at a time.
+const chunkSize = 64 << 10
+
+// Lexer tokenizes Jack source on demand, reading from the underlying
+// reader only as far as the tokens asked for require.
+//
+// Input is scanned out of a window string, and lexemes, raw text and
+// trivia are slices of that window rather than copies of it. Tokenize
+// makes the window the whole file, so nothing gets copied twice.
+type Lexer struct {
+	r    io.Reader // nil once the input is exhausted
+	buf  []byte
+	opts options
 
-	return s.bytes[s.index+1], nil
+	src   string // window onto the input
+	off   int    // read position in src
+	start int    // where the current token or trivia began in src
+	base  int    // offset in the input of src[0]
+
+	line      int
+	lineStart int // offset in the input of the current line
+
+	ahead []lexResult
+	diags Diagnostics
+	err   error // sticky read error
 }
 
-func (s *scanner) current() byte {
-	return s.bytes[s.index]
+type lexResult struct {
+	token Token
+	err   error
 }
 
-func NewScanner(b []byte) scanner {
-	return scanner{b, 0}
+func newLexer(r io.Reader, src string, opts []Option) *Lexer {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return &Lexer{
+		r:    r,
+		opts: o,
+		src:  src,
+		line: 1,
+	}
 }
 
-func isNumber(b byte) bool {
-	_, err := strconv.ParseInt(string(b), 10, 8)
-	return err == nil
+func NewLexer(r io.Reader, opts ...Option) *Lexer {
+	return newLexer(r, "", opts)
 }
 
-func isLetter(c byte) bool {
-	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+// Next consumes and returns the next token. Lexical problems are
+// reported as a Diagnostic (or Diagnostics) error, usually alongside
+// an ERROR token; the lexer carries on with the input that follows.
+func (l *Lexer) Next() (Token, error) {
+	if len(l.ahead) > 0 {
+		res := l.ahead[0]
+		l.ahead = l.ahead[1:]
+		return res.token, res.err
+	}
+
+	return l.scan()
 }
 
-func Tokenize(r io.Reader) ([]Token, error) {
-	chars, err := io.ReadAll(r)
-	if err != nil {
-		return nil, err
+// Peek returns the token n places ahead without consuming it.
+func (l *Lexer) Peek(n int) (Token, error) {
+	if n < 0 {
+		return Token{}, errors.New("negative peek")
 	}
-	var parseError error
-	parseError = nil
-	scan := NewScanner(chars)
-	tokens := make([]Token, 0)
-	line := 0
-
-	writeInt := func() (string, error) {
-		var ss strings.Builder
-		for !scan.atEnd() && isNumber(scan.current()) {
-			ss.WriteByte(scan.current())
-			scan.advance()
-		}
-
-		if !scan.atEnd() && isLetter(scan.current()) {
-			return "", errors.New("letter found " + string(scan.current()))
-		}
-
-		return ss.String(), nil
-	}
-
-	for !scan.atEnd() {
-		ch := scan.current()
-		pair, ok := mp[string(ch)]
-		switch {
-		// symbols
-		case ch == '/':
-			peek, err := scan.peek()
-			if err != nil {
-				parseError = err
-			} else {
-				if peek == '/' { // single comment
-					for !scan.atEnd() && scan.current() != '\n' {
-						scan.advance()
-					}
-				} else if peek == '*' { // multi line comment
-					scan.advance()
-					peek, _ = scan.peek()
-					for !scan.atEnd() && (scan.current() != '*' || peek != '/') {
-						scan.advance()
-						peek, _ = scan.peek()
-					}
-					scan.advance()
-				} else {
-					tokens = append(tokens, NewToken(string(ch), line, SYMBOL, SYM_SLASH))
-				}
+
+	for len(l.ahead) <= n {
+		token, err := l.scan()
+		l.ahead = append(l.ahead, lexResult{token, err})
+	}
+
+	res := l.ahead[n]
+	return res.token, res.err
+}
+
+// fill reads from r until the window holds n bytes past the read
+// position, dropping whatever came before the current token. It
+// reports whether there was enough input left.
+func (l *Lexer) fill(n int) bool {
+	for l.r != nil && len(l.src)-l.off < n {
+		if l.buf == nil {
+			l.buf = make([]byte, chunkSize)
+		}
+
+		read, err := l.r.Read(l.buf)
+		if read > 0 {
+			l.base += l.start
+			l.src = l.src[l.start:] + string(l.buf[:read])
+			l.off -= l.start
+			l.start = 0
+		}
+		if err != nil {
+			if err != io.EOF {
+				l.err = err
 			}
-			scan.advance()
-		case ch == '"':
-			var ss strings.Builder
-			scan.advance()
-			for !scan.atEnd() && (scan.current() != '\n' && scan.current() != '"') {
-				ss.WriteByte(scan.current())
-				scan.advance()
+			l.r = nil
+		}
+	}
+
+	return len(l.src)-l.off >= n
+}
+
+// peekByte returns the byte n places past the read position.
+func (l *Lexer) peekByte(n int) (byte, bool) {
+	if l.off+n < len(l.src) {
+		return l.src[l.off+n], true
+	}
+	if !l.fill(n + 1) {
+		return 0, false
+	}
+
+	return l.src[l.off+n], true
+}
+
+// advance consumes a single byte, keeping track of where we are.
+func (l *Lexer) advance() byte {
+	if l.off >= len(l.src) && !l.fill(1) {
+		return 0
+	}
+
+	b := l.src[l.off]
+	l.off++
+	if b == '\n' {
+		l.line++
+		l.lineStart = l.base + l.off
+	}
+
+	return b
+}
+
+// skipWhile consumes bytes for as long as their being in class matches
+// in. It must not be used to skip over '\n', which advance counts.
+func (l *Lexer) skipWhile(class byteClass, in bool) {
+	for {
+		for l.off < len(l.src) && classes[l.src[l.off]].is(class) == in {
+			l.off++
+		}
+		if l.off < len(l.src) || !l.fill(1) {
+			return
+		}
+	}
+}
+
+// position returns the Position of the read position.
+func (l *Lexer) position() Position {
+	offset := l.base + l.off
+	return Position{l.opts.filename, l.line, offset - l.lineStart + 1, offset}
+}
+
+// mark starts a new token or piece of trivia at the read position.
+func (l *Lexer) mark() {
+	l.start = l.off
+}
+
+// text returns the input consumed since the mark.
+func (l *Lexer) text() string {
+	return l.src[l.start:l.off]
+}
+
+// report records a diagnostic against the token being scanned.
+func (l *Lexer) report(code Code, pos Position, format string, args ...interface{}) {
+	l.diags = append(l.diags, NewDiagnostic(SeverityError, code, pos, format, args...))
+}
+
+// Diagnostics returns every lexical problem found so far.
+func (l *Lexer) Diagnostics() Diagnostics {
+	return l.diags
+}
+
+// scan reads the next token, returning whatever diagnostics were
+// reported along the way as its error.
+func (l *Lexer) scan() (Token, error) {
+	start := len(l.diags)
+	token := l.scanToken()
+
+	switch found := l.diags[start:]; {
+	case token.Tokentype == EOF && l.err != nil:
+		return token, l.err
+	case len(found) == 1:
+		return token, found[0]
+	case len(found) > 1:
+		return token, append(Diagnostics(nil), found...)
+	}
+
+	return token, nil
+}
+
+func (l *Lexer) scanToken() Token {
+	leading := l.skipTrivia(false)
+	token := l.scanLexeme()
+	if token.Tokentype != EOF {
+		token.Trailing = l.skipTrivia(true)
+	}
+	token.Leading = leading
+
+	return token
+}
+
+// token returns a token spelled as the input consumed since the mark.
+func (l *Lexer) token(lexeme string, pos Position, tt TokenType, st TokenSubtype) Token {
+	token := NewToken(lexeme, pos, l.position(), tt, st)
+	token.Raw = l.text()
+
+	return token
+}
+
+func (l *Lexer) scanLexeme() Token {
+	pos := l.position()
+	ch, ok := l.peekByte(0)
+	if !ok {
+		return NewToken("", pos, pos, EOF, NONE)
+	}
+
+	l.mark()
+	switch class := classes[ch]; {
+	case ch == '"':
+		return l.scanString(pos)
+	case class.is(classSymbol):
+		l.advance()
+		if next, _ := l.peekByte(0); next == '=' && l.opts.compound {
+			if pair, ok := compoundSymbols[l.src[l.start:l.off+1]]; ok {
+				l.advance()
+				return l.token(l.text(), pos, pair.tt, pair.st)
 			}
+		}
+		pair := symbols[ch]
+		return l.token(l.text(), pos, pair.tt, pair.st)
+	case ch == '\'' && l.opts.literals:
+		return l.scanCharacter(pos)
+	case ch == '0' && l.opts.literals && l.radixPrefix():
+		return l.scanRadix(pos)
+	case class.is(classDigit):
+		l.skipWhile(classDigit, true)
+
+		if ch, ok := l.peekByte(0); ok && isLetter(ch) {
+			// swallow the rest of the word so it is reported once
+			l.skipWhile(classIdent, true)
+			l.report(CodeMalformedNumber, pos, "malformed number %s", l.text())
+			return l.token(l.text(), pos, ERROR, NONE)
+		}
+		return l.token(l.text(), pos, INT_CONSTANT, NONE)
+	case class.is(classIdentStart): // identifier, or keyword
+		l.skipWhile(classIdent, true)
+
+		lexeme := l.text()
+		if pair, ok := mp[lexeme]; ok { // keyword
+			return l.token(lexeme, pos, pair.tt, pair.st)
+		}
+		if pair, ok := statementKeywords[lexeme]; ok && l.opts.statements {
+			return l.token(lexeme, pos, pair.tt, pair.st)
+		}
+		if pair, ok := constantKeywords[lexeme]; ok && l.opts.constants {
+			return l.token(lexeme, pos, pair.tt, pair.st)
+		}
+		return l.token(lexeme, pos, IDENTIFIER, NONE)
+	default: // unrecognized
+		l.advance()
+		l.report(CodeIllegalCharacter, pos, "illegal character %q", ch)
+		return l.token(l.text(), pos, ERROR, NONE)
+	}
+}
 
-			sres := ss.String()
-			if !scan.atEnd() && scan.current() == '"' {
-				tokens = append(tokens, NewToken(sres, line, STRING_CONSTANT, NONE))
-				scan.advance()
-			} else {
-				parseError = errors.New("unterminated string")
-				tokens = append(tokens, NewToken(sres, line, ERROR, NONE))
+// scanString scans a string constant. The lexeme is a slice of the
+// input unless an escape sequence has to be decoded.
+func (l *Lexer) scanString(pos Position) Token {
+	l.advance() // opening quote
+
+	var decoded []byte
+	escaped := false
+	for {
+		ch, ok := l.peekByte(0)
+		if !ok || isNewline(ch) || ch == '"' {
+			break
+		}
+
+		if ch == '\\' && l.opts.escapes {
+			if !escaped {
+				decoded = append(decoded, l.text()[1:]...)
+				escaped = true
 			}
-		case ok && pair.tt == SYMBOL:
-			tokens = append(tokens, NewToken(string(ch), line, pair.tt, pair.st))
-			scan.advance()
-		case isNumber(ch):
-			tt := INT_CONSTANT
-			st := NONE
-			numberStr, err := writeInt()
-			if err != nil {
-				tt = ERROR
-				parseError = err
+			if b, ok := l.scanEscape(); ok {
+				decoded = append(decoded, b)
 			}
-			tokens = append(tokens, NewToken(numberStr, line, TokenType(tt), TokenSubtype(st)))
+			continue
+		}
 
-		case isLetter(ch) || ch == '_': // identifier, or keyword
-			var ss strings.Builder
-			for !scan.atEnd() && isLetter(scan.current()) {
-				ss.WriteByte(scan.current())
-				scan.advance()
+		l.advance()
+		if escaped {
+			decoded = append(decoded, ch)
+		}
+	}
+
+	lexeme := l.text()[1:]
+	if escaped {
+		lexeme = string(decoded)
+	}
+
+	if ch, _ := l.peekByte(0); ch == '"' {
+		l.advance()
+		return l.token(lexeme, pos, STRING_CONSTANT, NONE)
+	}
+	l.report(CodeUnterminatedString, pos, "string literal not terminated")
+	return l.token(lexeme, pos, ERROR, NONE)
+}
+
+// Tokenize reads all of r and returns its tokens, leaving out the
+// final EOF token unless lexing WithTrivia, where it is kept for the
+// trivia at the end of the file. If anything was wrong with the input
+// the error is a Diagnostics listing every problem found.
+func Tokenize(r io.Reader, opts ...Option) ([]Token, error) {
+	// read straight into the string the lexer slices, sized up front
+	// when r knows how much it holds
+	var src strings.Builder
+	if r, ok := r.(interface{ Len() int }); ok {
+		src.Grow(r.Len())
+	}
+	if _, err := io.Copy(&src, r); err != nil {
+		return nil, err
+	}
+
+	lexer := newLexer(nil, src.String(), opts)
+	// one more for the EOF token kept WithTrivia
+	tokens := make([]Token, 0, countTokens(lexer.src)+1)
+
+	for {
+		// problems are collected in lexer.Diagnostics()
+		token, _ := lexer.Next()
+		if token.Tokentype == EOF {
+			if lexer.opts.trivia {
+				tokens = append(tokens, token)
+			}
+			return tokens, lexer.Diagnostics().Err()
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+// countTokens counts the symbols and the runs of identifier and digit
+// bytes outside comments and string constants, which for valid Jack is
+// the number of tokens or a few more. Tokens are big, so sizing the
+// slice this way wastes far less than a bytes-per-token guess.
+func countTokens(src string) int {
+	n := 0
+	for i := 0; i < len(src); i++ {
+		switch ch := src[i]; {
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && !isNewline(src[i]) {
+				i++
 			}
-			sres := ss.String()
-			pair, ok = mp[sres]
-			if ok { // keyword
-				tokens = append(tokens, NewToken(sres, line, pair.tt, pair.st))
-			} else { // identifier
-				tokens = append(tokens, NewToken(sres, line, IDENTIFIER, NONE))
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return n
+			}
+			i += end + 3
+		case ch == '"':
+			n++
+			for i++; i < len(src) && src[i] != '"' && !isNewline(src[i]); i++ {
+			}
+		case classes[ch].is(classSymbol):
+			n++
+		case classes[ch].is(classIdent):
+			n++
+			for i+1 < len(src) && classes[src[i+1]].is(classIdent) {
+				i++
 			}
-		default: // whitespace or unrecognized
-			scan.advance()
 		}
+	}
 
-		// scan.advance()
-		line++
+	return n
+}
+
+// Render turns tokens back into source text, writing each token as it
+// was originally spelled with a single space in between. The result
+// tokenizes to the same stream, minus trivia and positions.
+func Render(tokens []Token) string {
+	var ss strings.Builder
+	for i, token := range tokens {
+		if token.Tokentype == EOF {
+			break
+		}
+		if i > 0 {
+			ss.WriteByte(' ')
+		}
+		ss.WriteString(token.Raw)
+	}
+
+	return ss.String()
+}
+
+type sliceStream struct {
+	tokens []Token
+	index  int
+}
+
+// NewSliceStream returns a TokenStream over already tokenized input.
+func NewSliceStream(tokens []Token) TokenStream {
+	return &sliceStream{tokens, 0}
+}
+
+func (s *sliceStream) Next() (Token, error) {
+	token, err := s.Peek(0)
+	if s.index < len(s.tokens) {
+		s.index++
+	}
+
+	return token, err
+}
+
+func (s *sliceStream) Peek(n int) (Token, error) {
+	if n < 0 {
+		return Token{}, errors.New("negative peek")
+	}
+	if s.index+n >= len(s.tokens) {
+		var end Position
+		if len(s.tokens) > 0 {
+			end = s.tokens[len(s.tokens)-1].End
+		}
+		return NewToken("", end, end, EOF, NONE), nil
 	}
 
-	return tokens, parseError
+	return s.tokens[s.index+n], nil
 }
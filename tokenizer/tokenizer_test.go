@@ -0,0 +1,399 @@
+package jack_tokenizer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestTokenizePositions(t *testing.T) {
+	src := "class Main {\n  // comment\n  field int x;\n}\n"
+	tests := []struct {
+		lexeme string
+		pos    Position
+		end    Position
+	}{
+		{"class", Position{"Main.jack", 1, 1, 0}, Position{"Main.jack", 1, 6, 5}},
+		{"Main", Position{"Main.jack", 1, 7, 6}, Position{"Main.jack", 1, 11, 10}},
+		{"{", Position{"Main.jack", 1, 12, 11}, Position{"Main.jack", 1, 13, 12}},
+		{"field", Position{"Main.jack", 3, 3, 28}, Position{"Main.jack", 3, 8, 33}},
+		{"int", Position{"Main.jack", 3, 9, 34}, Position{"Main.jack", 3, 12, 37}},
+		{"x", Position{"Main.jack", 3, 13, 38}, Position{"Main.jack", 3, 14, 39}},
+		{";", Position{"Main.jack", 3, 14, 39}, Position{"Main.jack", 3, 15, 40}},
+		{"}", Position{"Main.jack", 4, 1, 41}, Position{"Main.jack", 4, 2, 42}},
+	}
+
+	tokens, err := Tokenize(strings.NewReader(src), WithFilename("Main.jack"))
+	if err != nil {
+		t.Fatalf("failed to tokenize: %s", err)
+	}
+	if len(tokens) != len(tests) {
+		t.Fatalf("got %d tokens, wanted %d", len(tokens), len(tests))
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.lexeme, func(t *testing.T) {
+			got := tokens[i]
+			if got.Lexeme != tt.lexeme {
+				t.Errorf("lexeme: got %q, wanted %q", got.Lexeme, tt.lexeme)
+			}
+			if got.Pos != tt.pos {
+				t.Errorf("pos: got %v, wanted %v", got.Pos, tt.pos)
+			}
+			if got.End != tt.end {
+				t.Errorf("end: got %v, wanted %v", got.End, tt.end)
+			}
+		})
+	}
+}
+
+func TestPositionString(t *testing.T) {
+	tests := []struct {
+		pos  Position
+		want string
+	}{
+		{Position{"File.jack", 12, 7, 100}, "File.jack:12:7"},
+		{Position{"", 3, 1, 20}, "3:1"},
+		{Position{}, "-"},
+	}
+	for _, tt := range tests {
+		if got := tt.pos.String(); got != tt.want {
+			t.Errorf("got %q, wanted %q", got, tt.want)
+		}
+	}
+}
+
+func TestLexerPeekAndNext(t *testing.T) {
+	lexer := NewLexer(strings.NewReader("let x = 1;"))
+
+	peeked, err := lexer.Peek(2)
+	if err != nil || peeked.Lexeme != "=" {
+		t.Fatalf("Peek(2): got %q (%v), wanted %q", peeked.Lexeme, err, "=")
+	}
+
+	for _, want := range []string{"let", "x", "=", "1", ";"} {
+		token, err := lexer.Next()
+		if err != nil {
+			t.Fatalf("Next: %s", err)
+		}
+		if token.Lexeme != want {
+			t.Errorf("Next: got %q, wanted %q", token.Lexeme, want)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		token, err := lexer.Next()
+		if err != nil || token.Tokentype != EOF {
+			t.Errorf("Next at end: got %v (%v), wanted EOF", token.Tokentype, err)
+		}
+	}
+}
+
+func TestSliceStreamMatchesLexer(t *testing.T) {
+	src := "class Main { function void main() { return; } }"
+	tokens, err := Tokenize(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("failed to tokenize: %s", err)
+	}
+
+	stream := NewSliceStream(tokens)
+	lexer := NewLexer(strings.NewReader(src))
+	for {
+		want, _ := lexer.Next()
+		got, _ := stream.Next()
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, wanted %v", got, want)
+		}
+		if want.Tokentype == EOF {
+			break
+		}
+	}
+}
+
+func TestLexerReadsInPieces(t *testing.T) {
+	src := "/** Doc. */\nclass Main {\n  field int x; // x\n  method void f() { do g(\"hi\\n\", 0x1F, 'a'); }\n}\n"
+	opts := []Option{WithTrivia(), WithEscapes(), WithExtendedLiterals()}
+	tokens, err := Tokenize(strings.NewReader(src), opts...)
+	if err != nil {
+		t.Fatalf("failed to tokenize: %s", err)
+	}
+
+	// one byte at a time, every token and comment straddles a read
+	lexer := NewLexer(iotest.OneByteReader(strings.NewReader(src)), opts...)
+	for i, want := range tokens {
+		got, err := lexer.Next()
+		if err != nil {
+			t.Fatalf("token %d: %s", i, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("token %d: got %v, wanted %v", i, got, want)
+		}
+	}
+}
+
+func TestTokenizeReportsEveryProblem(t *testing.T) {
+	src := "let s = \"open;\nlet x = 12ab # 3;\n/* never closed"
+	tests := []struct {
+		code Code
+		pos  Position
+	}{
+		{CodeUnterminatedString, Position{"", 1, 9, 8}},
+		{CodeMalformedNumber, Position{"", 2, 9, 23}},
+		{CodeIllegalCharacter, Position{"", 2, 14, 28}},
+		{CodeUnterminatedComment, Position{"", 3, 1, 33}},
+	}
+
+	tokens, err := Tokenize(strings.NewReader(src))
+	diags, ok := err.(Diagnostics)
+	if !ok {
+		t.Fatalf("got error %v, wanted Diagnostics", err)
+	}
+	if len(diags) != len(tests) {
+		t.Fatalf("got %d diagnostics, wanted %d:\n%s", len(diags), len(tests), diags)
+	}
+	for i, tt := range tests {
+		if diags[i].Code != tt.code || diags[i].Pos != tt.pos {
+			t.Errorf("diagnostic %d: got %s at %v, wanted %s at %v", i, diags[i].Code, diags[i].Pos, tt.code, tt.pos)
+		}
+		if diags[i].Severity != SeverityError {
+			t.Errorf("diagnostic %d: got severity %s, wanted error", i, diags[i].Severity)
+		}
+	}
+
+	// the lexer kept going: the tokens after each problem are still there
+	last := tokens[len(tokens)-1]
+	if last.Lexeme != ";" || last.Pos.Line != 2 {
+		t.Errorf("last token: got %q on line %d, wanted \";\" on line 2", last.Lexeme, last.Pos.Line)
+	}
+}
+
+func TestTriviaRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"empty", ""},
+		{"comments", "// header\r\n/** Doc. */\nclass Main { // trailing\n\t/* block\n spanning */ field int x; /**/\n}\n"},
+		{"errors", "let s = \"open\nlet x = 12ab # 3;\n/* never closed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lexer := NewLexer(strings.NewReader(tt.src), WithTrivia())
+			var ss strings.Builder
+			for {
+				token, _ := lexer.Next()
+				ss.WriteString(token.Source())
+				if token.Tokentype == EOF {
+					break
+				}
+			}
+			if ss.String() != tt.src {
+				t.Errorf("got %q, wanted %q", ss.String(), tt.src)
+			}
+		})
+	}
+}
+
+func TestTriviaAttachment(t *testing.T) {
+	src := "/** Doc. */\nclass Main { // trailing\n}\n// tail\n"
+	tokens, err := Tokenize(strings.NewReader(src), WithTrivia())
+	if err != nil {
+		t.Fatalf("failed to tokenize: %s", err)
+	}
+
+	class := tokens[0]
+	if len(class.Leading) != 2 || class.Leading[0].Kind != TRIVIA_DOC_COMMENT || class.Leading[0].Text != "/** Doc. */" {
+		t.Errorf("class: got leading %v, wanted a doc comment then a newline", class.Leading)
+	}
+
+	brace := tokens[2]
+	if len(brace.Trailing) != 2 || brace.Trailing[1].Kind != TRIVIA_LINE_COMMENT {
+		t.Errorf("{: got trailing %v, wanted a space then a line comment", brace.Trailing)
+	}
+
+	closing := tokens[3]
+	if len(closing.Leading) != 1 || closing.Leading[0].Kind != TRIVIA_NEWLINE {
+		t.Errorf("}: got leading %v, wanted a single newline", closing.Leading)
+	}
+
+	eof := tokens[len(tokens)-1]
+	if eof.Tokentype != EOF || len(eof.Leading) != 3 || eof.Leading[1].Text != "// tail" {
+		t.Errorf("EOF: got %v with leading %v, wanted the final comment", eof.Tokentype, eof.Leading)
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		src    string
+		lexeme string
+		codes  []Code
+	}{
+		{`"a\nb"`, "a\nb", nil},
+		{`"say \"hi\""`, `say "hi"`, nil},
+		{`"back\\slash\ttab"`, "back\\slash\ttab", nil},
+		{`"\u{128}\u{65}"`, "\x80A", nil},
+		{`"\q"`, "", []Code{CodeInvalidEscape}},
+		{`"\u{1000}\u{}"`, "", []Code{CodeInvalidEscape, CodeInvalidEscape}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			lexer := NewLexer(strings.NewReader(tt.src), WithEscapes())
+			token, _ := lexer.Next()
+			if token.Tokentype != STRING_CONSTANT || token.Lexeme != tt.lexeme {
+				t.Errorf("got %v %q, wanted STRING_CONSTANT %q", token.Tokentype, token.Lexeme, tt.lexeme)
+			}
+			if token.Raw != tt.src {
+				t.Errorf("raw: got %q, wanted %q", token.Raw, tt.src)
+			}
+
+			diags := lexer.Diagnostics()
+			if len(diags) != len(tt.codes) {
+				t.Fatalf("got diagnostics %v, wanted %v", diags, tt.codes)
+			}
+			for i, code := range tt.codes {
+				if diags[i].Code != code {
+					t.Errorf("diagnostic %d: got %s, wanted %s", i, diags[i].Code, code)
+				}
+			}
+		})
+	}
+}
+
+func TestExtendedLiterals(t *testing.T) {
+	tests := []struct {
+		src    string
+		tt     TokenType
+		lexeme string
+		code   Code
+	}{
+		{"0x7FFF", INT_CONSTANT, "32767", ""},
+		{"0b1010_0101", INT_CONSTANT, "165", ""},
+		{"0X10", INT_CONSTANT, "16", ""},
+		{"'A'", INT_CONSTANT, "65", ""},
+		{`'\''`, INT_CONSTANT, "39", ""},
+		{`'\n'`, INT_CONSTANT, "10", ""},
+		{"0x", ERROR, "0x", CodeMalformedNumber},
+		{"0b102", ERROR, "0b102", CodeMalformedNumber},
+		{"0xFF_", ERROR, "0xFF_", CodeMalformedNumber},
+		{"'AB'", ERROR, "'AB'", CodeMalformedCharacter},
+		{"''", ERROR, "''", CodeMalformedCharacter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			lexer := NewLexer(strings.NewReader(tt.src), WithExtendedLiterals())
+			token, _ := lexer.Next()
+			if token.Tokentype != tt.tt || token.Lexeme != tt.lexeme || token.Raw != tt.src {
+				t.Errorf("got %v %q (raw %q), wanted %v %q", token.Tokentype, token.Lexeme, token.Raw, tt.tt, tt.lexeme)
+			}
+			if next, _ := lexer.Next(); next.Tokentype != EOF {
+				t.Errorf("literal not consumed whole, next token is %q", next.Raw)
+			}
+
+			diags := lexer.Diagnostics()
+			if tt.code == "" && len(diags) != 0 {
+				t.Errorf("unexpected diagnostics %v", diags)
+			}
+			if tt.code != "" && (len(diags) != 1 || diags[0].Code != tt.code) {
+				t.Errorf("got diagnostics %v, wanted %s", diags, tt.code)
+			}
+		})
+	}
+}
+
+func TestExtendedStatementKeywords(t *testing.T) {
+	tests := []struct {
+		src      string
+		standard TokenType
+		extended TokenSubtype
+	}{
+		{"for", IDENTIFIER, KW_FOR},
+		{"break", IDENTIFIER, KW_BREAK},
+		{"continue", IDENTIFIER, KW_CONTINUE},
+		{"while", KEYWORD, KW_WHILE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			token, _ := NewLexer(strings.NewReader(tt.src)).Next()
+			if token.Tokentype != tt.standard {
+				t.Errorf("standard: got %v, wanted %v", token.Tokentype, tt.standard)
+			}
+
+			token, _ = NewLexer(strings.NewReader(tt.src), WithExtendedStatements()).Next()
+			if token.Tokentype != KEYWORD || token.Subtype != tt.extended {
+				t.Errorf("extended: got %v %v, wanted KEYWORD %v", token.Tokentype, token.Subtype, tt.extended)
+			}
+			if got := token.Subtype.Spelling(); got != tt.src {
+				t.Errorf("spelling: got %q, wanted %q", got, tt.src)
+			}
+		})
+	}
+}
+
+func TestCompoundAssignment(t *testing.T) {
+	tests := []struct {
+		src      string
+		standard int
+		extended TokenSubtype
+		op       TokenSubtype
+	}{
+		{"+=", 2, SYM_PLUS_EQUALS, SYM_PLUS},
+		{"-=", 2, SYM_MINUS_EQUALS, SYM_MINUS},
+		{"*=", 2, SYM_ASTERISK_EQUALS, SYM_ASTERISK},
+		{"/=", 2, SYM_SLASH_EQUALS, SYM_SLASH},
+		{"&=", 2, SYM_AMPERSAND_EQUALS, SYM_AMPERSAND},
+		{"|=", 2, SYM_PIPE_EQUALS, SYM_PIPE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			tokens, err := Tokenize(strings.NewReader(tt.src))
+			if err != nil || len(tokens) != tt.standard {
+				t.Errorf("standard: got %d tokens and %v, wanted %d", len(tokens), err, tt.standard)
+			}
+
+			tokens, err = Tokenize(strings.NewReader(tt.src), WithCompoundAssignment())
+			if err != nil || len(tokens) != 1 || tokens[0].Subtype != tt.extended {
+				t.Fatalf("extended: got %v and %v, wanted one %v", tokens, err, tt.extended)
+			}
+			if got := tokens[0].Subtype.Spelling(); got != tt.src {
+				t.Errorf("spelling: got %q, wanted %q", got, tt.src)
+			}
+			if got, ok := tokens[0].Subtype.AssignOp(); !ok || got != tt.op {
+				t.Errorf("operator: got %v, wanted %v", got, tt.op)
+			}
+		})
+	}
+
+	// '<' and '=' make no compound assignment, and '=' alone is no
+	// compound assignment either
+	tokens, _ := Tokenize(strings.NewReader("a <= b == c"), WithCompoundAssignment())
+	if got := Render(tokens); got != "a < = b = = c" {
+		t.Errorf("got %s, wanted a < = b = = c", got)
+	}
+	if _, ok := SYM_EQUALS.AssignOp(); ok {
+		t.Errorf("got = as a compound assignment")
+	}
+}
+
+func TestConstantKeywords(t *testing.T) {
+	tests := []struct {
+		src  string
+		want TokenSubtype
+	}{
+		{"const", KW_CONST},
+		{"enum", KW_ENUM},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			tokens, _ := Tokenize(strings.NewReader(tt.src))
+			if len(tokens) != 1 || tokens[0].Tokentype != IDENTIFIER {
+				t.Errorf("standard: got %v, wanted an identifier", tokens)
+			}
+
+			tokens, _ = Tokenize(strings.NewReader(tt.src), WithConstants())
+			if len(tokens) != 1 || tokens[0].Tokentype != KEYWORD || tokens[0].Subtype != tt.want {
+				t.Errorf("constants: got %v, wanted %v", tokens, tt.want)
+			}
+		})
+	}
+}
@@ -31,41 +31,52 @@ func _() {
 	_ = x[KW_ELSE-20]
 	_ = x[KW_WHILE-21]
 	_ = x[KW_RETURN-22]
-	_ = x[SYM_LEFT_BRACE-23]
-	_ = x[SYM_RIGHT_BRACE-24]
-	_ = x[SYM_LEFT_PAREN-25]
-	_ = x[SYM_RIGHT_PAREN-26]
-	_ = x[SYM_LEFT_BRACK-27]
-	_ = x[SYM_RIGHT_BRACK-28]
-	_ = x[SYM_PERIOD-29]
-	_ = x[SYM_COMMA-30]
-	_ = x[SYM_SEMICOLON-31]
-	_ = x[SYM_PLUS-32]
-	_ = x[SYM_MINUS-33]
-	_ = x[SYM_ASTERISK-34]
-	_ = x[SYM_SLASH-35]
-	_ = x[SYM_AMPERSAND-36]
-	_ = x[SYM_PIPE-37]
-	_ = x[SYM_LESS_THAN-38]
-	_ = x[SYM_GREATER_THAN-39]
-	_ = x[SYM_EQUALS-40]
-	_ = x[SYM_TILDE-41]
+	_ = x[KW_FOR-23]
+	_ = x[KW_BREAK-24]
+	_ = x[KW_CONTINUE-25]
+	_ = x[KW_CONST-26]
+	_ = x[KW_ENUM-27]
+	_ = x[SYM_LEFT_BRACE-28]
+	_ = x[SYM_RIGHT_BRACE-29]
+	_ = x[SYM_LEFT_PAREN-30]
+	_ = x[SYM_RIGHT_PAREN-31]
+	_ = x[SYM_LEFT_BRACK-32]
+	_ = x[SYM_RIGHT_BRACK-33]
+	_ = x[SYM_PERIOD-34]
+	_ = x[SYM_COMMA-35]
+	_ = x[SYM_SEMICOLON-36]
+	_ = x[SYM_PLUS-37]
+	_ = x[SYM_MINUS-38]
+	_ = x[SYM_ASTERISK-39]
+	_ = x[SYM_SLASH-40]
+	_ = x[SYM_AMPERSAND-41]
+	_ = x[SYM_PIPE-42]
+	_ = x[SYM_LESS_THAN-43]
+	_ = x[SYM_GREATER_THAN-44]
+	_ = x[SYM_EQUALS-45]
+	_ = x[SYM_TILDE-46]
+	_ = x[SYM_PLUS_EQUALS-47]
+	_ = x[SYM_MINUS_EQUALS-48]
+	_ = x[SYM_ASTERISK_EQUALS-49]
+	_ = x[SYM_SLASH_EQUALS-50]
+	_ = x[SYM_AMPERSAND_EQUALS-51]
+	_ = x[SYM_PIPE_EQUALS-52]
 }
 
 const (
 	_TokenSubtype_name_0 = "UNKNOWN"
-	_TokenSubtype_name_1 = "NONEKW_CLASSKW_CONSTRUCTORKW_FUNCTIONKW_METHODKW_FIELDKW_STATICKW_VARKW_INTKW_CHARKW_BOOLEANKW_VOIDKW_TRUEKW_FALSEKW_NULLKW_THISKW_LETKW_DOKW_IFKW_ELSEKW_WHILEKW_RETURNSYM_LEFT_BRACESYM_RIGHT_BRACESYM_LEFT_PARENSYM_RIGHT_PARENSYM_LEFT_BRACKSYM_RIGHT_BRACKSYM_PERIODSYM_COMMASYM_SEMICOLONSYM_PLUSSYM_MINUSSYM_ASTERISKSYM_SLASHSYM_AMPERSANDSYM_PIPESYM_LESS_THANSYM_GREATER_THANSYM_EQUALSSYM_TILDE"
+	_TokenSubtype_name_1 = "NONEKW_CLASSKW_CONSTRUCTORKW_FUNCTIONKW_METHODKW_FIELDKW_STATICKW_VARKW_INTKW_CHARKW_BOOLEANKW_VOIDKW_TRUEKW_FALSEKW_NULLKW_THISKW_LETKW_DOKW_IFKW_ELSEKW_WHILEKW_RETURNKW_FORKW_BREAKKW_CONTINUEKW_CONSTKW_ENUMSYM_LEFT_BRACESYM_RIGHT_BRACESYM_LEFT_PARENSYM_RIGHT_PARENSYM_LEFT_BRACKSYM_RIGHT_BRACKSYM_PERIODSYM_COMMASYM_SEMICOLONSYM_PLUSSYM_MINUSSYM_ASTERISKSYM_SLASHSYM_AMPERSANDSYM_PIPESYM_LESS_THANSYM_GREATER_THANSYM_EQUALSSYM_TILDESYM_PLUS_EQUALSSYM_MINUS_EQUALSSYM_ASTERISK_EQUALSSYM_SLASH_EQUALSSYM_AMPERSAND_EQUALSSYM_PIPE_EQUALS"
 )
 
 var (
-	_TokenSubtype_index_1 = [...]uint16{0, 4, 12, 26, 37, 46, 54, 63, 69, 75, 82, 92, 99, 106, 114, 121, 128, 134, 139, 144, 151, 159, 168, 182, 197, 211, 226, 240, 255, 265, 274, 287, 295, 304, 316, 325, 338, 346, 359, 375, 385, 394}
+	_TokenSubtype_index_1 = [...]uint16{0, 4, 12, 26, 37, 46, 54, 63, 69, 75, 82, 92, 99, 106, 114, 121, 128, 134, 139, 144, 151, 159, 168, 174, 182, 193, 201, 208, 222, 237, 251, 266, 280, 295, 305, 314, 327, 335, 344, 356, 365, 378, 386, 399, 415, 425, 434, 449, 465, 484, 500, 520, 535}
 )
 
 func (i TokenSubtype) String() string {
 	switch {
 	case i == -1:
 		return _TokenSubtype_name_0
-	case 1 <= i && i <= 41:
+	case 1 <= i && i <= 52:
 		i -= 1
 		return _TokenSubtype_name_1[_TokenSubtype_index_1[i]:_TokenSubtype_index_1[i+1]]
 	default:
@@ -13,11 +13,12 @@ func _() {
 	_ = x[INT_CONSTANT-2]
 	_ = x[STRING_CONSTANT-3]
 	_ = x[IDENTIFIER-4]
+	_ = x[EOF-5]
 }
 
-const _TokenType_name = "KEYWORDSYMBOLINT_CONSTANTSTRING_CONSTANTIDENTIFIER"
+const _TokenType_name = "KEYWORDSYMBOLINT_CONSTANTSTRING_CONSTANTIDENTIFIEREOF"
 
-var _TokenType_index = [...]uint8{0, 7, 13, 25, 40, 50}
+var _TokenType_index = [...]uint8{0, 7, 13, 25, 40, 50, 53}
 
 func (i TokenType) String() string {
 	if i < 0 || i >= TokenType(len(_TokenType_index)-1) {
@@ -0,0 +1,112 @@
+package jack_tokenizer
+
+type TriviaKind int
+
+const (
+	TRIVIA_SPACE TriviaKind = iota
+	TRIVIA_NEWLINE
+	TRIVIA_LINE_COMMENT  // '//' up to the end of the line
+	TRIVIA_BLOCK_COMMENT // '/* ... */'
+	TRIVIA_DOC_COMMENT   // '/** ... */'
+)
+
+func (k TriviaKind) String() string {
+	switch k {
+	case TRIVIA_SPACE:
+		return "TRIVIA_SPACE"
+	case TRIVIA_NEWLINE:
+		return "TRIVIA_NEWLINE"
+	case TRIVIA_LINE_COMMENT:
+		return "TRIVIA_LINE_COMMENT"
+	case TRIVIA_BLOCK_COMMENT:
+		return "TRIVIA_BLOCK_COMMENT"
+	case TRIVIA_DOC_COMMENT:
+		return "TRIVIA_DOC_COMMENT"
+	default:
+		return "TriviaKind(?)"
+	}
+}
+
+// Trivia is a piece of source that carries no meaning for the
+// grammar: whitespace and comments.
+//
+// A token owns the trivia on its own line after it as Trailing, up to
+// but not including the line break. Everything else between two tokens
+// is Leading trivia of the second one, so the final comments of a file
+// end up on the EOF token.
+type Trivia struct {
+	Kind TriviaKind
+	Text string
+	Pos  Position
+}
+
+// WithTrivia keeps whitespace and comments, attached to the tokens
+// around them, instead of throwing them away.
+func WithTrivia() Option {
+	return func(o *options) {
+		o.trivia = true
+	}
+}
+
+// skipTrivia consumes whitespace and comments. When trailing is set it
+// stops at the first line break. The trivia is only collected when
+// lexing WithTrivia.
+func (l *Lexer) skipTrivia(trailing bool) []Trivia {
+	var trivia []Trivia
+
+	for {
+		pos := l.position()
+		ch, ok := l.peekByte(0)
+		if !ok {
+			return trivia
+		}
+
+		l.mark()
+		var kind TriviaKind
+		switch peek, _ := l.peekByte(1); {
+		case isNewline(ch):
+			if trailing {
+				return trivia
+			}
+			for ch, ok := l.peekByte(0); ok && isNewline(ch); ch, ok = l.peekByte(0) {
+				l.advance()
+			}
+			kind = TRIVIA_NEWLINE
+		case isSpace(ch):
+			l.skipWhile(classSpace, true)
+			kind = TRIVIA_SPACE
+		case ch == '/' && peek == '/':
+			l.skipWhile(classNewline, false)
+			kind = TRIVIA_LINE_COMMENT
+		case ch == '/' && peek == '*':
+			kind = TRIVIA_BLOCK_COMMENT
+			if third, _ := l.peekByte(2); third == '*' {
+				if fourth, _ := l.peekByte(3); fourth != '/' {
+					kind = TRIVIA_DOC_COMMENT
+				}
+			}
+
+			l.advance()
+			l.advance()
+			for {
+				ch, ok := l.peekByte(0)
+				if !ok {
+					l.report(CodeUnterminatedComment, pos, "comment not terminated")
+					break
+				}
+				peek, _ = l.peekByte(1)
+				l.advance()
+				if ch == '*' && peek == '/' {
+					l.advance()
+					break
+				}
+			}
+		default:
+			return trivia
+		}
+
+		if l.opts.trivia {
+			trivia = append(trivia, Trivia{kind, l.text(), pos})
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package jack_tokenwriter
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"strings"
@@ -8,30 +9,113 @@ import (
 	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
 )
 
-func CreateXMLWriter(tokens []jack_tokenizer.Token) func(io.Writer) {
-	var ss strings.Builder
+// A TokenWriter renders a stream of tokens in some output format.
+// EOF tokens are accepted and ignored, so a Lexer can be drained
+// straight into a TokenWriter.
+type TokenWriter interface {
+	WriteToken(token jack_tokenizer.Token) error
+	// Flush writes out whatever the format still needs to be complete.
+	// It does not close the underlying writer.
+	Flush() error
+}
 
-	return func(w io.Writer) {
-		for _, token := range tokens {
-			tagname, ok := keyword2tag[token.Tokentype]
-			if !ok {
-				tagname = "unknown"
-			}
-			escaped, ok := escapeLexeme[token.Lexeme]
-			if !ok {
-				escaped = token.Lexeme
-			}
-			ss.WriteString(wrap(tagname, escaped, 1))
+// Formats lists the names accepted by NewWriter.
+var Formats = []string{"xml", "json", "tsv"}
+
+// NewWriter returns the TokenWriter for the named format.
+func NewWriter(format string, w io.Writer) (TokenWriter, error) {
+	switch format {
+	case "xml":
+		return NewXMLWriter(w), nil
+	case "json", "jsonl":
+		return NewJSONWriter(w), nil
+	case "tsv":
+		return NewTSVWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unknown token format %q, wanted one of %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// WriteAll writes every token to tw and flushes it.
+func WriteAll(tw TokenWriter, tokens []jack_tokenizer.Token) error {
+	for _, token := range tokens {
+		if err := tw.WriteToken(token); err != nil {
+			return err
 		}
-		var root strings.Builder
-		root.WriteString(wrap("tokens", "\n"+ss.String(), 0))
+	}
+
+	return tw.Flush()
+}
+
+func typeName(tt jack_tokenizer.TokenType) string {
+	if tt == jack_tokenizer.ERROR {
+		return "ERROR"
+	}
+
+	return tt.String()
+}
+
+type xmlWriter struct {
+	w       io.Writer
+	started bool
+}
+
+// NewXMLWriter writes tokens in the format of the nand2tetris
+// reference FooT.xml files: a <tokens> element holding one line per
+// token, such as "<keyword> class </keyword>". Lines end in "\n"; the
+// course's TextComparer does not care about line endings, so the output
+// compares equal to the CRLF reference files.
+func NewXMLWriter(w io.Writer) TokenWriter {
+	return &xmlWriter{w, false}
+}
 
-		io.WriteString(w, root.String())
+func (x *xmlWriter) start() error {
+	if x.started {
+		return nil
+	}
+	x.started = true
+
+	_, err := io.WriteString(x.w, "<tokens>\n")
+	return err
+}
+
+func (x *xmlWriter) WriteToken(token jack_tokenizer.Token) error {
+	if token.Tokentype == jack_tokenizer.EOF {
+		return nil
+	}
+	if err := x.start(); err != nil {
+		return err
+	}
+
+	tagname, ok := keyword2tag[token.Tokentype]
+	if !ok {
+		tagname = "unknown"
+	}
+
+	_, err := io.WriteString(x.w, wrap(tagname, EscapeXML(token.Lexeme)))
+	return err
+}
+
+func (x *xmlWriter) Flush() error {
+	if err := x.start(); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(x.w, "</tokens>\n")
+	return err
+}
+
+// CreateXMLWriter renders tokens as XML.
+//
+// Deprecated: write errors are lost; use NewXMLWriter and WriteAll.
+func CreateXMLWriter(tokens []jack_tokenizer.Token) func(io.Writer) {
+	return func(w io.Writer) {
+		WriteAll(NewXMLWriter(w), tokens)
 	}
 }
 
-func wrap(tagName, content string, tabs int) string {
-	return fmt.Sprintf("%s<%s>%s</%s>\n", strings.Repeat("\t", tabs), tagName, content, tagName)
+func wrap(tagName, content string) string {
+	return fmt.Sprintf("<%s> %s </%s>\n", tagName, content, tagName)
 }
 
 var keyword2tag = map[jack_tokenizer.TokenType]string{
@@ -42,9 +126,105 @@ var keyword2tag = map[jack_tokenizer.TokenType]string{
 	jack_tokenizer.IDENTIFIER:      "identifier",
 }
 
-var escapeLexeme = map[string]string{
-	"<":  "&lt;",
-	">":  "&gt;",
-	"\"": "&quot;",
-	"&":  "&amp;",
+var xmlEscaper = strings.NewReplacer(
+	"<", "&lt;",
+	">", "&gt;",
+	"\"", "&quot;",
+	"&", "&amp;",
+)
+
+// EscapeXML escapes every character of s that may not appear as is in
+// XML character data, the way the reference files do.
+func EscapeXML(s string) string {
+	return xmlEscaper.Replace(s)
+}
+
+type jsonPosition struct {
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Offset int    `json:"offset"`
+}
+
+type jsonToken struct {
+	Type    string       `json:"type"`
+	Subtype string       `json:"subtype"`
+	Lexeme  string       `json:"lexeme"`
+	Pos     jsonPosition `json:"pos"`
+	End     jsonPosition `json:"end"`
+}
+
+type jsonWriter struct {
+	enc *json.Encoder
+}
+
+// NewJSONWriter writes JSON Lines: one object per token holding its
+// type, subtype, lexeme and start and end positions.
+func NewJSONWriter(w io.Writer) TokenWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	return &jsonWriter{enc}
+}
+
+func toJSONPosition(p jack_tokenizer.Position) jsonPosition {
+	return jsonPosition{p.File, p.Line, p.Column, p.Offset}
+}
+
+func (j *jsonWriter) WriteToken(token jack_tokenizer.Token) error {
+	if token.Tokentype == jack_tokenizer.EOF {
+		return nil
+	}
+
+	return j.enc.Encode(jsonToken{
+		typeName(token.Tokentype),
+		token.Subtype.String(),
+		token.Lexeme,
+		toJSONPosition(token.Pos),
+		toJSONPosition(token.End),
+	})
+}
+
+func (j *jsonWriter) Flush() error {
+	return nil
+}
+
+type tsvWriter struct {
+	w       io.Writer
+	started bool
+}
+
+// NewTSVWriter writes a header line followed by one tab-separated line
+// per token: type, subtype, line, column and lexeme. Tabs, line breaks
+// and backslashes in lexemes are escaped as \t, \n, \r and \\.
+func NewTSVWriter(w io.Writer) TokenWriter {
+	return &tsvWriter{w, false}
+}
+
+var tsvEscaper = strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r")
+
+func (t *tsvWriter) start() error {
+	if t.started {
+		return nil
+	}
+	t.started = true
+
+	_, err := io.WriteString(t.w, "type\tsubtype\tline\tcolumn\tlexeme\n")
+	return err
+}
+
+func (t *tsvWriter) WriteToken(token jack_tokenizer.Token) error {
+	if token.Tokentype == jack_tokenizer.EOF {
+		return nil
+	}
+	if err := t.start(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(t.w, "%s\t%s\t%d\t%d\t%s\n", typeName(token.Tokentype), token.Subtype, token.Pos.Line, token.Pos.Column, tsvEscaper.Replace(token.Lexeme))
+	return err
+}
+
+func (t *tsvWriter) Flush() error {
+	return t.start()
 }
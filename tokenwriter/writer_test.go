@@ -1,6 +1,7 @@
 package jack_tokenwriter
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -13,10 +14,7 @@ func TestCreateXMLWriter(t *testing.T) {
 	tests := []struct {
 		name string
 	}{
-		// TODO: Add test cases
-		// {"/home/jr/school/cs3650/nand2tetris/projects/10/ArrayTest"},
-		// {"/home/jr/school/cs3650/nand2tetris/projects/10/ExpressionLessSquare"},
-		{"/home/jr/school/cs3650/nand2tetris/projects/10/Square"},
+		{"testdata"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -46,3 +44,83 @@ func TestCreateXMLWriter(t *testing.T) {
 		})
 	}
 }
+
+func TestTokenWriterFormats(t *testing.T) {
+	src := "let s = \"a\tb\";"
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"xml", "<tokens>\n<keyword> let </keyword>\n<identifier> s </identifier>\n<symbol> = </symbol>\n<stringConstant> a\tb </stringConstant>\n<symbol> ; </symbol>\n</tokens>\n"},
+		{"json", `{"type":"KEYWORD","subtype":"KW_LET","lexeme":"let","pos":{"file":"Main.jack","line":1,"column":1,"offset":0},"end":{"file":"Main.jack","line":1,"column":4,"offset":3}}` + "\n" +
+			`{"type":"IDENTIFIER","subtype":"NONE","lexeme":"s","pos":{"file":"Main.jack","line":1,"column":5,"offset":4},"end":{"file":"Main.jack","line":1,"column":6,"offset":5}}` + "\n" +
+			`{"type":"SYMBOL","subtype":"SYM_EQUALS","lexeme":"=","pos":{"file":"Main.jack","line":1,"column":7,"offset":6},"end":{"file":"Main.jack","line":1,"column":8,"offset":7}}` + "\n" +
+			`{"type":"STRING_CONSTANT","subtype":"NONE","lexeme":"a\tb","pos":{"file":"Main.jack","line":1,"column":9,"offset":8},"end":{"file":"Main.jack","line":1,"column":14,"offset":13}}` + "\n" +
+			`{"type":"SYMBOL","subtype":"SYM_SEMICOLON","lexeme":";","pos":{"file":"Main.jack","line":1,"column":14,"offset":13},"end":{"file":"Main.jack","line":1,"column":15,"offset":14}}` + "\n"},
+		{"tsv", "type\tsubtype\tline\tcolumn\tlexeme\nKEYWORD\tKW_LET\t1\t1\tlet\nIDENTIFIER\tNONE\t1\t5\ts\nSYMBOL\tSYM_EQUALS\t1\t7\t=\nSTRING_CONSTANT\tNONE\t1\t9\ta\\tb\nSYMBOL\tSYM_SEMICOLON\t1\t14\t;\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			tokens, err := jack_tokenizer.Tokenize(strings.NewReader(src), jack_tokenizer.WithFilename("Main.jack"))
+			if err != nil {
+				t.Fatalf("failed to tokenize: %s", err)
+			}
+
+			var ss strings.Builder
+			tw, err := NewWriter(tt.format, &ss)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := WriteAll(tw, tokens); err != nil {
+				t.Fatal(err)
+			}
+			if ss.String() != tt.want {
+				t.Errorf("got\n%s\nwanted\n%s", ss.String(), tt.want)
+			}
+		})
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestTokenWriterReportsErrors(t *testing.T) {
+	tokens := []jack_tokenizer.Token{jack_tokenizer.NewToken("class", jack_tokenizer.Position{}, jack_tokenizer.Position{}, jack_tokenizer.KEYWORD, jack_tokenizer.KW_CLASS)}
+	for _, format := range Formats {
+		tw, _ := NewWriter(format, failingWriter{})
+		if err := WriteAll(tw, tokens); err == nil {
+			t.Errorf("%s: write error was dropped", format)
+		}
+	}
+}
+
+func TestXMLWriterMatchesReference(t *testing.T) {
+	file, err := os.Open("testdata/Main.jack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	tokens, err := jack_tokenizer.Tokenize(file)
+	if err != nil {
+		t.Fatalf("failed to tokenize: %s", err)
+	}
+
+	var ss strings.Builder
+	if err := WriteAll(NewXMLWriter(&ss), tokens); err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := os.ReadFile("testdata/MainT.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the reference files use CRLF line endings, which the course's
+	// comparer ignores
+	if got := ss.String(); got != strings.ReplaceAll(string(want), "\r\n", "\n") {
+		t.Errorf("got\n%s\nwanted\n%s", got, want)
+	}
+}
@@ -0,0 +1,402 @@
+package jack_types
+
+import (
+	"fmt"
+
+	jack_ast "github.com/renojcpp/n2t-compiler/ast"
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+)
+
+const (
+	CodeTypeMismatch  jack_tokenizer.Code = "type-mismatch"
+	CodeLooseInt      jack_tokenizer.Code = "loose-int"
+	CodeVoidValue     jack_tokenizer.Code = "void-value"
+	CodeReturnType    jack_tokenizer.Code = "return-type"
+	CodeUnknownMethod jack_tokenizer.Code = "unknown-method"
+	CodeNotObject     jack_tokenizer.Code = "not-object"
+	CodeUnknownType   jack_tokenizer.Code = "unknown-type"
+)
+
+type options struct {
+	strict bool
+}
+
+// An Option configures a Checker.
+type Option func(*options)
+
+// WithStrict makes loose uses of ints errors instead of warnings.
+func WithStrict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// A Checker checks the classes of one program, knowing the declarations
+// of all of them and of the Jack OS.
+type Checker struct {
+	opts    options
+	classes map[string]*classInfo
+	enums   map[string]bool
+}
+
+type classInfo struct {
+	class       *jack_ast.Class
+	vars        map[string]Type // statics and fields
+	consts      map[string]Type
+	subroutines map[string]*jack_ast.SubroutineDec
+}
+
+// NewChecker returns a Checker for the program made of classes. A class
+// of the program may stand in for one of the OS.
+func NewChecker(classes []*jack_ast.Class, opts ...Option) *Checker {
+	c := &Checker{
+		classes: make(map[string]*classInfo, len(classes)+len(OS)),
+		enums:   make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+
+	for _, list := range [][]*jack_ast.Class{classes, OS} {
+		for _, class := range list {
+			if _, ok := c.classes[class.Name.Name]; ok {
+				continue
+			}
+			c.classes[class.Name.Name] = &classInfo{class: class}
+			for _, dec := range class.Enums {
+				c.enums[dec.Name.Name] = true
+			}
+		}
+	}
+
+	// types can name enums, so those go first
+	for _, info := range c.classes {
+		info.vars = make(map[string]Type)
+		info.consts = make(map[string]Type)
+		info.subroutines = make(map[string]*jack_ast.SubroutineDec)
+		for _, dec := range info.class.Vars {
+			for _, name := range dec.Names {
+				info.vars[name.Name] = c.typeOf(dec.Type)
+			}
+		}
+		for _, dec := range info.class.Consts {
+			info.consts[dec.Name.Name] = c.typeOf(dec.Type)
+		}
+		for _, dec := range info.class.Subroutines {
+			if _, ok := info.subroutines[dec.Name.Name]; !ok {
+				info.subroutines[dec.Name.Name] = dec
+			}
+		}
+	}
+
+	return c
+}
+
+// typeOf returns the type a type name stands for. Enums are ints.
+func (c *Checker) typeOf(name *jack_ast.TypeName) Type {
+	if c.enums[name.Name] {
+		return Int
+	}
+	return Type(name.Name)
+}
+
+// Check checks class, one of the classes of the program, and returns the
+// problems found.
+func (c *Checker) Check(class *jack_ast.Class) jack_tokenizer.Diagnostics {
+	info, ok := c.classes[class.Name.Name]
+	if !ok || info.class != class {
+		info = &classInfo{class: class, vars: make(map[string]Type), consts: make(map[string]Type)}
+	}
+
+	s := &checker{Checker: c, info: info}
+	for _, dec := range class.Vars {
+		s.declared(dec.Type)
+	}
+	for _, dec := range class.Subroutines {
+		s.subroutine(dec)
+	}
+
+	return s.diags
+}
+
+// checker checks a single class.
+type checker struct {
+	*Checker
+	info   *classInfo
+	sub    *jack_ast.SubroutineDec
+	locals map[string]Type // arguments and local variables
+	diags  jack_tokenizer.Diagnostics
+}
+
+func (s *checker) errorf(pos jack_tokenizer.Position, code jack_tokenizer.Code, format string, args ...interface{}) {
+	s.diags = append(s.diags, jack_tokenizer.NewDiagnostic(jack_tokenizer.SeverityError, code, pos, format, args...))
+}
+
+// declared checks that a declared type exists.
+func (s *checker) declared(name *jack_ast.TypeName) {
+	if name.Keyword != jack_tokenizer.NONE || s.enums[name.Name] {
+		return
+	}
+	if _, ok := s.classes[name.Name]; !ok {
+		s.errorf(name.Pos(), CodeUnknownType, "unknown type %s", name.Name)
+	}
+}
+
+func (s *checker) subroutine(dec *jack_ast.SubroutineDec) {
+	s.sub = dec
+	s.locals = make(map[string]Type)
+
+	s.declared(dec.Return)
+	for _, param := range dec.Params {
+		s.declared(param.Type)
+		s.locals[param.Name.Name] = s.typeOf(param.Type)
+	}
+	for _, dec := range dec.Body.Vars {
+		s.declared(dec.Type)
+		for _, name := range dec.Names {
+			s.locals[name.Name] = s.typeOf(dec.Type)
+		}
+	}
+
+	s.statements(dec.Body.Stmts)
+}
+
+// convert checks that a value of type from may be used as a to.
+func (s *checker) convert(pos jack_tokenizer.Position, code jack_tokenizer.Code, to, from Type, context string) {
+	switch convert(to, from) {
+	case loose:
+		severity := jack_tokenizer.SeverityWarning
+		if s.opts.strict {
+			severity = jack_tokenizer.SeverityError
+		}
+		s.diags = append(s.diags, jack_tokenizer.NewDiagnostic(severity, CodeLooseInt, pos,
+			"%s used as %s in %s", from, to, context))
+	case disallowed:
+		s.errorf(pos, code, "cannot use %s as %s in %s", from, to, context)
+	}
+}
+
+func (s *checker) statements(stmts []jack_ast.Stmt) {
+	for _, stmt := range stmts {
+		s.statement(stmt)
+	}
+}
+
+func (s *checker) statement(stmt jack_ast.Stmt) {
+	switch stmt := stmt.(type) {
+	case *jack_ast.LetStmt:
+		s.let(stmt)
+	case *jack_ast.IfStmt:
+		s.condition(stmt.Cond)
+		s.statements(stmt.Body.Stmts)
+		if stmt.Else != nil {
+			s.statement(stmt.Else)
+		}
+	case *jack_ast.WhileStmt:
+		s.condition(stmt.Cond)
+		s.statements(stmt.Body.Stmts)
+	case *jack_ast.ForStmt:
+		if stmt.Init != nil {
+			s.statement(stmt.Init)
+		}
+		if stmt.Cond != nil {
+			s.condition(stmt.Cond)
+		}
+		if stmt.Post != nil {
+			s.statement(stmt.Post)
+		}
+		s.statements(stmt.Body.Stmts)
+	case *jack_ast.DoStmt:
+		s.call(stmt.Call)
+	case *jack_ast.ReturnStmt:
+		if stmt.Value == nil {
+			break
+		}
+		t := s.value(stmt.Value)
+		if ret := s.typeOf(s.sub.Return); ret != Void {
+			s.convert(stmt.Value.Pos(), CodeReturnType, ret, t, "return")
+		}
+	case *jack_ast.Block:
+		s.statements(stmt.Stmts)
+	}
+}
+
+func (s *checker) let(stmt *jack_ast.LetStmt) {
+	target := s.variable(stmt.Name.Name)
+	if stmt.Index != nil {
+		s.convert(stmt.Name.Pos(), CodeTypeMismatch, Array, target, "index expression")
+		s.convert(stmt.Index.Pos(), CodeTypeMismatch, Int, s.value(stmt.Index), "index")
+		// array elements have no type
+		target = Unknown
+	}
+
+	value := s.value(stmt.Value)
+	if op, ok := stmt.Assign.AssignOp(); ok {
+		context := "operand of " + op.Spelling()
+		s.convert(stmt.Name.Pos(), CodeTypeMismatch, Int, target, context)
+		s.convert(stmt.Value.Pos(), CodeTypeMismatch, Int, value, context)
+		return
+	}
+	s.convert(stmt.Value.Pos(), CodeTypeMismatch, target, value, "assignment to "+stmt.Name.Name)
+}
+
+func (s *checker) condition(cond jack_ast.Expr) {
+	s.convert(cond.Pos(), CodeTypeMismatch, Boolean, s.value(cond), "condition")
+}
+
+// variable returns the type of a variable, or Unknown for anything that
+// is not one.
+func (s *checker) variable(name string) Type {
+	if t, ok := s.locals[name]; ok {
+		return t
+	}
+	if t, ok := s.info.vars[name]; ok {
+		return t
+	}
+	return Unknown
+}
+
+// value returns the type of expr, which is used as a value.
+func (s *checker) value(expr jack_ast.Expr) Type {
+	t := s.expr(expr)
+	if t == Void {
+		call, ok := expr.(*jack_ast.CallExpr)
+		if !ok {
+			return Unknown
+		}
+		name := call.Name.Name
+		if call.Receiver != nil {
+			name = call.Receiver.Name + "." + name
+		}
+		s.errorf(call.Name.Pos(), CodeVoidValue, "%s is void and has no value", name)
+		return Unknown
+	}
+	return t
+}
+
+// expr returns the type of expr.
+func (s *checker) expr(expr jack_ast.Expr) Type {
+	switch expr := expr.(type) {
+	case *jack_ast.IntLit:
+		if len(expr.Raw) > 0 && expr.Raw[0] == '\'' {
+			return Char
+		}
+		return Int
+	case *jack_ast.StringLit:
+		return String
+	case *jack_ast.KeywordLit:
+		switch expr.Keyword {
+		case jack_tokenizer.KW_TRUE, jack_tokenizer.KW_FALSE:
+			return Boolean
+		case jack_tokenizer.KW_NULL:
+			return Null
+		case jack_tokenizer.KW_THIS:
+			return Type(s.info.class.Name.Name)
+		}
+	case *jack_ast.Ident:
+		if t := s.variable(expr.Name); t != Unknown {
+			return t
+		}
+		return s.info.consts[expr.Name]
+	case *jack_ast.SelectorExpr:
+		if s.enums[expr.X.Name] {
+			return Int
+		}
+		if info, ok := s.classes[expr.X.Name]; ok {
+			return info.consts[expr.Sel.Name]
+		}
+	case *jack_ast.ParenExpr:
+		return s.value(expr.X)
+	case *jack_ast.UnaryExpr:
+		x := s.value(expr.X)
+		if expr.Op == jack_tokenizer.SYM_TILDE && x == Boolean {
+			return Boolean
+		}
+		s.convert(expr.X.Pos(), CodeTypeMismatch, Int, x, "operand of "+expr.Op.Spelling())
+		return Int
+	case *jack_ast.BinaryExpr:
+		return s.binary(expr)
+	case *jack_ast.IndexExpr:
+		s.convert(expr.X.Pos(), CodeTypeMismatch, Array, s.variable(expr.X.Name), "index expression")
+		s.convert(expr.Index.Pos(), CodeTypeMismatch, Int, s.value(expr.Index), "index")
+	case *jack_ast.CallExpr:
+		return s.call(expr)
+	}
+
+	return Unknown
+}
+
+func (s *checker) binary(expr *jack_ast.BinaryExpr) Type {
+	x, y := s.value(expr.X), s.value(expr.Y)
+	context := "operand of " + expr.Op.Spelling()
+
+	switch expr.Op {
+	case jack_tokenizer.SYM_EQUALS:
+		return Boolean
+	case jack_tokenizer.SYM_AMPERSAND, jack_tokenizer.SYM_PIPE:
+		if x == Boolean {
+			s.convert(expr.Y.Pos(), CodeTypeMismatch, Boolean, y, context)
+			return Boolean
+		}
+	}
+
+	s.convert(expr.X.Pos(), CodeTypeMismatch, Int, x, context)
+	s.convert(expr.Y.Pos(), CodeTypeMismatch, Int, y, context)
+	if expr.Op == jack_tokenizer.SYM_LESS_THAN || expr.Op == jack_tokenizer.SYM_GREATER_THAN {
+		return Boolean
+	}
+	return Int
+}
+
+// call checks the arguments of a call against the parameters of the
+// subroutine called, and returns what it returns. Calls the compiler
+// cannot resolve are left for it to report.
+func (s *checker) call(call *jack_ast.CallExpr) Type {
+	class := s.info
+	if call.Receiver != nil {
+		if t := s.variable(call.Receiver.Name); t != Unknown {
+			class = s.object(call, t)
+		} else {
+			class = s.classes[call.Receiver.Name]
+		}
+	}
+
+	var dec *jack_ast.SubroutineDec
+	if class != nil {
+		dec = class.subroutines[call.Name.Name]
+	}
+	if dec == nil || len(dec.Params) != len(call.Args) {
+		for _, arg := range call.Args {
+			s.value(arg)
+		}
+		if dec == nil {
+			return Unknown
+		}
+		return s.typeOf(dec.Return)
+	}
+
+	context := fmt.Sprintf("argument to %s.%s", class.class.Name.Name, call.Name.Name)
+	for i, arg := range call.Args {
+		s.convert(arg.Pos(), CodeTypeMismatch, s.typeOf(dec.Params[i].Type), s.value(arg), context)
+	}
+	return s.typeOf(dec.Return)
+}
+
+// object returns the class of a variable of type t a method is called
+// on, reporting a variable that holds no object or whose class has no
+// such method.
+func (s *checker) object(call *jack_ast.CallExpr, t Type) *classInfo {
+	if !t.IsObject() {
+		s.errorf(call.Receiver.Pos(), CodeNotObject, "%s is of type %s, which has no methods", call.Receiver.Name, t)
+		return nil
+	}
+
+	class, ok := s.classes[string(t)]
+	if !ok {
+		return nil
+	}
+	if _, ok := class.subroutines[call.Name.Name]; !ok {
+		s.errorf(call.Name.Pos(), CodeUnknownMethod, "%s has no method %s", t, call.Name.Name)
+	}
+	return class
+}
@@ -0,0 +1,119 @@
+package jack_types
+
+import (
+	"strings"
+	"testing"
+
+	jack_ast "github.com/renojcpp/n2t-compiler/ast"
+	jack_parser "github.com/renojcpp/n2t-compiler/parser"
+	jack_tokenizer "github.com/renojcpp/n2t-compiler/tokenizer"
+)
+
+func parse(t *testing.T, src string) *jack_ast.Class {
+	t.Helper()
+
+	lexer := jack_tokenizer.NewLexer(strings.NewReader(src), jack_tokenizer.WithConstants(), jack_tokenizer.WithExtendedLiterals())
+	class, err := jack_parser.ParseFile(lexer, jack_parser.WithConstants())
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+
+	return class
+}
+
+func TestCheck(t *testing.T) {
+	point := parse(t, `class Point {
+  field int x;
+  enum Dir { UP, DOWN }
+  constructor Point new() { return this; }
+  method void move(Dir d) { return; }
+  method int getX() { return x; }
+}`)
+
+	tests := []struct {
+		name string
+		body string
+		want jack_tokenizer.Code
+	}{
+		{"int", "let n = p.getX() + 'A';", ""},
+		{"object", "let p = Point.new(); do p.move(Dir.UP);", ""},
+		{"null", "let p = null;", ""},
+		{"array", "let a = Array.new(2); let a[0] = p; let p = a[1]; do Memory.deAlloc(p);", ""},
+		{"enum", "let d = Dir.DOWN; let n = d + 1;", ""},
+		{"boolean", "let b = (n < 1) & ~b;", ""},
+		{"boolean to class", "let p = true;", CodeTypeMismatch},
+		{"class to class", "let s = p;", CodeTypeMismatch},
+		{"unknown method", "do p.jump();", CodeUnknownMethod},
+		{"method on int", "do n.getX();", CodeNotObject},
+		{"void value", "let n = p.move(1);", CodeVoidValue},
+		{"void operand", "let n = 1 + (p.move(1));", CodeVoidValue},
+		{"argument", "do p.move(b);", CodeLooseInt},
+		{"condition", "if (n) { let n = 0; }", CodeLooseInt},
+		{"int to object", "let s = 0;", CodeLooseInt},
+		{"boolean argument to class", "do Output.printString(true);", CodeTypeMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			main := parse(t, `class Main {
+  function void f() {
+    var int n;
+    var boolean b;
+    var Point p;
+    var String s;
+    var Array a;
+    var Dir d;
+    `+tt.body+`
+    return;
+  }
+}`)
+			diags := NewChecker([]*jack_ast.Class{point, main}).Check(main)
+			if tt.want == "" && len(diags) != 0 {
+				t.Fatalf("unexpected problems: %s", diags)
+			}
+			if tt.want != "" && (len(diags) != 1 || diags[0].Code != tt.want) {
+				t.Errorf("got %v, wanted %s", diags, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckReturn(t *testing.T) {
+	tests := []struct {
+		name string
+		dec  string
+		want jack_tokenizer.Code
+	}{
+		{"int", "function int f() { return 1; }", ""},
+		{"char as int", "function int f() { return 'a'; }", ""},
+		{"this", "constructor Main new() { return this; }", ""},
+		{"loose", "function boolean f() { return 1; }", CodeLooseInt},
+		{"wrong", "function Main f() { return false; }", CodeReturnType},
+		{"unknown type", "function Game f() { return null; }", CodeUnknownType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			main := parse(t, "class Main { "+tt.dec+" }")
+			diags := NewChecker([]*jack_ast.Class{main}).Check(main)
+			if tt.want == "" && len(diags) != 0 {
+				t.Fatalf("unexpected problems: %s", diags)
+			}
+			if tt.want != "" && (len(diags) != 1 || diags[0].Code != tt.want) {
+				t.Errorf("got %v, wanted %s", diags, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrict(t *testing.T) {
+	main := parse(t, "class Main { function void f(int n) { while (n) { let n = n - 1; } return; } }")
+
+	diags := NewChecker([]*jack_ast.Class{main}).Check(main)
+	if len(diags) != 1 || diags[0].Severity != jack_tokenizer.SeverityWarning {
+		t.Errorf("got %v, wanted a warning", diags)
+	}
+
+	diags = NewChecker([]*jack_ast.Class{main}, WithStrict()).Check(main)
+	if len(diags) != 1 || diags[0].Severity != jack_tokenizer.SeverityError || diags[0].Code != CodeLooseInt {
+		t.Errorf("got %v, wanted a loose-int error", diags)
+	}
+}
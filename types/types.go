@@ -0,0 +1,81 @@
+// Package jack_types checks that the values of a Jack program are used
+// as their types allow.
+//
+// Jack is loosely typed: int and char mix freely, and ints stand in for
+// booleans and for objects wherever the VM lets them. Such loose uses
+// of an int are warnings, or errors when checking WithStrict. Using a
+// boolean as an object, one class as another, or a void call as a
+// value is always an error.
+package jack_types
+
+// A Type is the type of a Jack value: int, char, boolean, void or the
+// name of a class, Array and String included.
+type Type string
+
+const (
+	Int     Type = "int"
+	Char    Type = "char"
+	Boolean Type = "boolean"
+	Void    Type = "void"
+	Array   Type = "Array"
+	String  Type = "String"
+
+	// Null is the type of null, which is any object.
+	Null Type = "null"
+	// Unknown is the type of a value that could not be worked out.
+	// Every use of it is allowed.
+	Unknown Type = ""
+)
+
+// IsObject reports whether values of t are objects.
+func (t Type) IsObject() bool {
+	switch t {
+	case Int, Char, Boolean, Void, Null, Unknown:
+		return false
+	}
+	return true
+}
+
+// IsNumeric reports whether t is int or char.
+func (t Type) IsNumeric() bool {
+	return t == Int || t == Char
+}
+
+func (t Type) String() string {
+	if t == Unknown {
+		return "unknown"
+	}
+	return string(t)
+}
+
+// conversion tells how well a value of one type stands in for another.
+type conversion int
+
+const (
+	allowed conversion = iota
+	loose              // the kind of use Jack allows, but that is often a mistake
+	disallowed
+)
+
+// convert tells how well a value of type from can be used as a to.
+func convert(to, from Type) conversion {
+	switch {
+	case to == from, to == Unknown, from == Unknown:
+		return allowed
+	case to.IsNumeric() && from.IsNumeric():
+		return allowed
+	case from == Null && to.IsObject():
+		return allowed
+	case to.IsObject() && from.IsObject():
+		// Array is the VM's untyped memory, which any object is
+		// built on
+		if to == Array || from == Array {
+			return allowed
+		}
+		return disallowed
+	case to == Boolean && from.IsObject(), to.IsObject() && from == Boolean:
+		return disallowed
+	}
+
+	return loose
+}